@@ -2,9 +2,10 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
-	"strings"
+	"path/filepath"
+	"text/template"
 	"time"
 
 	"ai-browser-agent/browser"
@@ -14,242 +15,406 @@ import (
 )
 
 type AIAgent struct {
-	client        *openai.Client
-	browser       *browser.BrowserManager
-	conversation  []openai.ChatCompletionMessage
-	maxIterations int
+	provider     LLMProvider
+	browser      *browser.BrowserManager
+	tools        *tools.Registry
+	conversation []Message
+	opts         Options
+	systemPrompt *template.Template
+	observers    []Observer
+	session      SessionUsage
+	// taskIndex — индекс сообщения с текущей задачей в conversation.
+	taskIndex int
 }
 
-func NewAIAgent(browserManager *browser.BrowserManager) (*AIAgent, error) {
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		return nil, fmt.Errorf("OPENAI_API_KEY не установлен. Установите переменную окружения")
+// Options — настройки агента. Нулевые Model, MaxIterations, RunDir, Limits,
+// SystemPrompt, Language, ContextBudget и Prices заменяются значениями по
+// умолчанию; нулевые Temperature, TaskTimeout, Vision и MaxTaskCost
+// используются как есть.
+type Options struct {
+	Model         string
+	Temperature   float32
+	MaxIterations int
+	// TaskTimeout — предельное время выполнения одной задачи. Ноль
+	// отключает ограничение.
+	TaskTimeout time.Duration
+	// RunDir — каталог, куда сохраняются скриншоты и другие файлы запуска.
+	RunDir string
+	// Vision включает передачу скриншотов модели. Для моделей без поддержки
+	// изображений скриншоты только сохраняются на диск.
+	Vision bool
+	Limits tools.Limits
+	// SystemPrompt — текст шаблона системного промпта (см. PromptData).
+	// По умолчанию используется встроенный шаблон prompts/system.tmpl.
+	SystemPrompt string
+	// Language — язык, на котором агент отвечает пользователю.
+	Language string
+	// SiteHints — подсказки для модели по конкретным сайтам, ключ — домен.
+	SiteHints map[string]string
+	// Observers получают события всех задач агента, например
+	// ConsoleRenderer для вывода хода выполнения в терминал. Без
+	// наблюдателей агент ничего не печатает.
+	Observers []Observer
+	// ContextBudget — предельный размер диалога в токенах (по оценке
+	// EstimateTokens). Перед запросом к модели диалог, который больше
+	// бюджета, сокращается.
+	ContextBudget int
+	// Prices — цены моделей для подсчета стоимости задач, ключ — имя
+	// модели. По умолчанию DefaultPrices.
+	Prices map[string]Price
+	// MaxTaskCost — предельная стоимость одной задачи в долларах. Задача,
+	// превысившая ее, останавливается с ошибкой ErrCostLimit. Ноль
+	// отключает ограничение.
+	MaxTaskCost float64
+	// ObserverFactories вызываются в NewAIAgent, их наблюдатели
+	// добавляются к Observers. Используются для наблюдателей, которым нужен
+	// браузер агента, например для записи трассировки.
+	ObserverFactories []ObserverFactory
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Model:         openai.GPT4TurboPreview,
+		Temperature:   0.7,
+		MaxIterations: 20,
+		TaskTimeout:   10 * time.Minute,
+		RunDir:        filepath.Join("runs", time.Now().Format("20060102-150405")),
+		Vision:        true,
+		Limits:        tools.DefaultLimits(),
+		SystemPrompt:  defaultSystemPrompt,
+		Language:      defaultLanguage,
+		ContextBudget: 60000,
+		Prices:        DefaultPrices(),
+	}
+}
+
+func NewAIAgent(provider LLMProvider, browserManager *browser.BrowserManager, opts Options) (*AIAgent, error) {
+	defaults := DefaultOptions()
+	if opts.Model == "" {
+		opts.Model = defaults.Model
+	}
+	if opts.MaxIterations <= 0 {
+		opts.MaxIterations = defaults.MaxIterations
+	}
+	if opts.RunDir == "" {
+		opts.RunDir = defaults.RunDir
+	}
+	if opts.Limits == (tools.Limits{}) {
+		opts.Limits = defaults.Limits
+	}
+	if opts.SystemPrompt == "" {
+		opts.SystemPrompt = defaults.SystemPrompt
+	}
+	if opts.Language == "" {
+		opts.Language = defaults.Language
+	}
+	if opts.ContextBudget <= 0 {
+		opts.ContextBudget = defaults.ContextBudget
+	}
+	if opts.Prices == nil {
+		opts.Prices = defaults.Prices
 	}
 
-	client := openai.NewClient(apiKey)
+	systemPrompt, err := ParseSystemPrompt(opts.SystemPrompt)
+	if err != nil {
+		return nil, err
+	}
 
 	agent := &AIAgent{
-		client:        client,
-		browser:       browserManager,
-		conversation:  []openai.ChatCompletionMessage{},
-		maxIterations: 20,
+		provider:     provider,
+		browser:      browserManager,
+		tools:        tools.Default,
+		conversation: []Message{},
+		opts:         opts,
+		systemPrompt: systemPrompt,
+		observers:    append([]Observer(nil), opts.Observers...),
+	}
+	for _, factory := range opts.ObserverFactories {
+		agent.observers = append(agent.observers, factory(browserManager, opts.RunDir))
 	}
 
-	agent.initializeSystemPrompt()
+	if err := agent.initializeSystemPrompt(); err != nil {
+		return nil, err
+	}
 
 	return agent, nil
 }
 
-func (a *AIAgent) initializeSystemPrompt() {
-	a.conversation = []openai.ChatCompletionMessage{
+func (a *AIAgent) initializeSystemPrompt() error {
+	prompt, err := a.renderSystemPrompt(TaskOptions{})
+	if err != nil {
+		return err
+	}
+
+	a.conversation = []Message{
 		{
-			Role: openai.ChatMessageRoleSystem,
+			Role:    RoleSystem,
+			Content: prompt,
 		},
 	}
+	a.taskIndex = 0
+	return nil
+}
+
+// TaskResult — итог выполнения задачи. Iterations, Duration, Usage и Cost
+// заполняются и тогда, когда задача завершилась ошибкой.
+type TaskResult struct {
+	Result     string
+	Iterations int
+	Duration   time.Duration
+	// Usage — токены всех запросов задачи к модели, включая пересказ
+	// диалога, Cost — их стоимость в долларах.
+	Usage Usage
+	Cost  float64
+	// Unpriced означает, что для части запросов цена модели неизвестна и
+	// Cost занижена.
+	Unpriced bool
+}
+
+func (a *AIAgent) ExecuteTask(ctx context.Context, task string) (string, error) {
+	res, err := a.ExecuteTaskWithOptions(ctx, task, TaskOptions{})
+	return res.Result, err
+}
+
+// ExecuteTaskWithOptions выполняет задачу с системным промптом, собранным
+// с учетом opts. Промпт пересобирается для каждой задачи, поэтому
+// переопределения не переходят на следующие задачи, а дата остается
+// актуальной.
+func (a *AIAgent) ExecuteTaskWithOptions(ctx context.Context, task string, opts TaskOptions) (TaskResult, error) {
+	prompt, err := a.renderSystemPrompt(opts)
+	if err != nil {
+		return TaskResult{}, err
+	}
+	a.conversation[0].Content = prompt
+
+	start := time.Now()
+	var res TaskResult
+	res.Result, err = a.runTask(ctx, task, opts, &res)
+	res.Duration = time.Since(start)
+	a.session.Tasks++
+
+	finished := TaskFinished{
+		Time:       time.Now(),
+		Result:     res.Result,
+		Iterations: res.Iterations,
+		Duration:   res.Duration,
+		Usage:      res.Usage,
+		Cost:       res.Cost,
+		Unpriced:   res.Unpriced,
+	}
+	if err != nil {
+		finished.Error = err.Error()
+	}
+	a.emit(opts.Observer, finished)
+
+	return res, err
 }
 
-func (a *AIAgent) ExecuteTask(task string) (string, error) {
-	fmt.Printf(" Задача: %s\n", task)
-	fmt.Println("Агент начинает выполнение...\n")
+func (a *AIAgent) runTask(ctx context.Context, task string, opts TaskOptions, res *TaskResult) (string, error) {
+	emit := func(e Event) {
+		a.emit(opts.Observer, e)
+	}
+
+	if a.opts.TaskTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.opts.TaskTimeout)
+		defer cancel()
+	}
+
+	emit(TaskStarted{Time: time.Now(), Task: task, MaxIterations: a.opts.MaxIterations})
 
-	a.conversation = append(a.conversation, openai.ChatCompletionMessage{
-		Role:    openai.ChatMessageRoleUser,
+	a.taskIndex = len(a.conversation)
+	a.conversation = append(a.conversation, Message{
+		Role:    RoleUser,
 		Content: task,
 	})
 
-	availableTools := convertToolsToOpenAI(tools.GetBrowserTools())
+	availableTools := convertTools(a.tools.Tools())
+
+	for iteration := 0; iteration < a.opts.MaxIterations; iteration++ {
+		if err := ctx.Err(); err != nil {
+			return "", interruptedError(err)
+		}
 
-	for iteration := 0; iteration < a.maxIterations; iteration++ {
-		fmt.Printf(" Итерация %d/%d\n", iteration+1, a.maxIterations)
+		res.Iterations = iteration + 1
+		emit(IterationStarted{Time: time.Now(), Iteration: res.Iterations, MaxIterations: a.opts.MaxIterations})
 
-		req := openai.ChatCompletionRequest{
-			Model:       openai.GPT4TurboPreview,
+		a.fitContext(ctx, res, emit)
+
+		req := ChatRequest{
+			Model:       a.opts.Model,
 			Messages:    a.conversation,
 			Tools:       availableTools,
-			ToolChoice:  nil,
-			Temperature: 0.7,
+			Temperature: a.opts.Temperature,
 		}
 
-		resp, err := a.client.CreateChatCompletion(context.Background(), req)
+		emit(ModelRequested{
+			Time:         time.Now(),
+			Iteration:    res.Iterations,
+			Model:        req.Model,
+			Messages:     append([]Message(nil), req.Messages...),
+			MessageCount: len(req.Messages),
+		})
+
+		requestStart := time.Now()
+		iteration := res.Iterations
+		resp, err := a.provider.Chat(withRetryHook(ctx, func(retry ModelRetry) {
+			emit(ModelRetried{
+				Time:      time.Now(),
+				Iteration: iteration,
+				Model:     retry.Model,
+				Next:      retry.Next,
+				Attempt:   retry.Attempt,
+				Delay:     retry.Delay,
+				Error:     retry.Err.Error(),
+			})
+		}), req)
 		if err != nil {
-			return "", fmt.Errorf("ошибка запроса к OpenAI: %w", err)
+			if ctx.Err() != nil {
+				return "", interruptedError(ctx.Err())
+			}
+			return "", fmt.Errorf("ошибка запроса к модели: %w", err)
+		}
+
+		model := req.Model
+		if resp.Model != "" {
+			model = resp.Model
 		}
 
-		assistantMessage := resp.Choices[0].Message
+		assistantMessage := resp.Message
 		a.conversation = append(a.conversation, assistantMessage)
+		emit(ModelResponded{
+			Time:      time.Now(),
+			Iteration: res.Iterations,
+			Model:     model,
+			Text:      assistantMessage.Content,
+			ToolCalls: assistantMessage.ToolCalls,
+			Usage:     resp.Usage,
+			Cost:      a.recordUsage(res, model, resp.Usage),
+			Duration:  time.Since(requestStart),
+		})
+
+		if err := a.checkCost(res); err != nil {
+			a.abandonToolCalls(assistantMessage.ToolCalls, err)
+			return "", err
+		}
 
 		if len(assistantMessage.ToolCalls) == 0 {
-			fmt.Println(" Агент завершил задачу без вызовов инструментов")
 			if assistantMessage.Content != "" {
 				return assistantMessage.Content, nil
 			}
 			continue
 		}
 
-		for _, toolCall := range assistantMessage.ToolCalls {
-			fmt.Printf(" Вызов инструмента: %s\n", toolCall.Function.Name)
+		var images []Image
+		for i, toolCall := range assistantMessage.ToolCalls {
+			if err := ctx.Err(); err != nil {
+				err = interruptedError(err)
+				a.abandonToolCalls(assistantMessage.ToolCalls[i:], err)
+				return "", err
+			}
 
-			result := a.executeTool(toolCall)
+			emit(ToolCalled{Time: time.Now(), Iteration: res.Iterations, Call: toolCall})
 
-			a.conversation = append(a.conversation, openai.ChatCompletionMessage{
-				Role:       openai.ChatMessageRoleTool,
+			toolStart := time.Now()
+			result, toolImages := a.executeTool(ctx, toolCall)
+			images = append(images, toolImages...)
+
+			a.conversation = append(a.conversation, Message{
+				Role:       RoleTool,
 				Content:    result.Content,
 				ToolCallID: toolCall.ID,
 			})
 
-			fmt.Printf(" Результат: %s\n\n", truncateString(result.Content, 200))
+			emit(ToolFinished{
+				Time:      time.Now(),
+				Iteration: res.Iterations,
+				Call:      toolCall,
+				Result:    result.Content,
+				IsError:   result.IsError,
+				Selector:  result.Selector,
+				Duration:  time.Since(toolStart),
+			})
 
-			if toolCall.Function.Name == "complete_task" {
+			if toolCall.Name == "complete_task" {
 				var args tools.CompleteTaskArgs
-				if err := tools.ParseArguments(toolCall.Function.Arguments, &args); err == nil {
+				if err := tools.ParseArguments(toolCall.Arguments, &args); err == nil {
+					a.abandonToolCalls(assistantMessage.ToolCalls[i+1:], errTaskCompleted)
 					return args.Result, nil
 				}
 			}
 		}
-	}
-
-	return "", fmt.Errorf("достигнуто максимальное количество итераций (%d). Задача может быть слишком сложной или требовать дополнительной информации", a.maxIterations)
-}
-
-func (a *AIAgent) executeTool(toolCall openai.ToolCall) tools.ToolResult {
-	switch toolCall.Function.Name {
-	case "navigate":
-		var args tools.NavigateArgs
-		if err := tools.ParseArguments(toolCall.Function.Arguments, &args); err != nil {
-			return tools.NewToolResult(toolCall.ID, tools.FormatError(err))
-		}
-		err := a.browser.Navigate(args.URL)
-		if err != nil {
-			return tools.NewToolResult(toolCall.ID, tools.FormatError(err))
-		}
-		return tools.NewToolResult(toolCall.ID, fmt.Sprintf("Успешно перешел на страницу: %s", args.URL))
-
-	case "get_page_content":
-		html, err := a.browser.GetPageContent()
-		if err != nil {
-			return tools.NewToolResult(toolCall.ID, tools.FormatError(err))
-		}
-		text, err := a.browser.GetPageText()
-		if err != nil {
-			return tools.NewToolResult(toolCall.ID, tools.FormatError(err))
-		}
-		content := fmt.Sprintf("HTML (первые 5000 символов): %s\n\nТекст страницы (первые 3000 символов): %s",
-			truncateString(html, 5000),
-			truncateString(text, 3000))
-		return tools.NewToolResult(toolCall.ID, content)
-
-	case "get_page_info":
-		url := a.browser.GetPageURL()
-		title := a.browser.GetPageTitle()
-		info := fmt.Sprintf("URL: %s\nЗаголовок: %s", url, title)
-		return tools.NewToolResult(toolCall.ID, info)
-
-	case "click_element":
-		var args tools.ClickElementArgs
-		if err := tools.ParseArguments(toolCall.Function.Arguments, &args); err != nil {
-			return tools.NewToolResult(toolCall.ID, tools.FormatError(err))
-		}
-		err := a.browser.ClickElement(args.Selector)
-		if err != nil {
-			return tools.NewToolResult(toolCall.ID, tools.FormatError(err))
-		}
-		return tools.NewToolResult(toolCall.ID, fmt.Sprintf("Успешно кликнул на элемент: %s", args.Selector))
 
-	case "fill_input":
-		var args tools.FillInputArgs
-		if err := tools.ParseArguments(toolCall.Function.Arguments, &args); err != nil {
-			return tools.NewToolResult(toolCall.ID, tools.FormatError(err))
-		}
-		err := a.browser.FillInput(args.Selector, args.Text)
-		if err != nil {
-			return tools.NewToolResult(toolCall.ID, tools.FormatError(err))
+		// Изображения нельзя передать в ответе инструмента, поэтому они
+		// отправляются отдельным сообщением перед следующим запросом к модели.
+		if len(images) > 0 {
+			a.conversation = append(a.conversation, Message{
+				Role:    RoleUser,
+				Content: "Скриншоты, запрошенные инструментами:",
+				Images:  images,
+			})
 		}
-		return tools.NewToolResult(toolCall.ID, fmt.Sprintf("Успешно заполнил поле %s текстом: %s", args.Selector, args.Text))
+	}
 
-	case "get_elements":
-		var args tools.GetElementsArgs
-		if err := tools.ParseArguments(toolCall.Function.Arguments, &args); err != nil {
-			return tools.NewToolResult(toolCall.ID, tools.FormatError(err))
-		}
-		elements, err := a.browser.GetElements(args.Selector)
-		if err != nil {
-			return tools.NewToolResult(toolCall.ID, tools.FormatError(err))
-		}
-		if len(elements) == 0 {
-			return tools.NewToolResult(toolCall.ID, fmt.Sprintf("Элементы с селектором '%s' не найдены", args.Selector))
-		}
-		var info strings.Builder
-		info.WriteString(fmt.Sprintf("Найдено элементов: %d\n", len(elements)))
-		for i, elem := range elements {
-			if i >= 10 {
-				info.WriteString(fmt.Sprintf("... и еще %d элементов\n", len(elements)-10))
-				break
-			}
-			elemInfo := fmt.Sprintf("%d. Селектор: %s, Тег: %s, Текст: %s",
-				i+1, elem.Selector, elem.Tag, truncateString(elem.Text, 100))
-			if elem.Href != nil {
-				elemInfo += fmt.Sprintf(", Ссылка: %s", *elem.Href)
-			}
-			if elem.ID != nil {
-				elemInfo += fmt.Sprintf(", ID: %s", *elem.ID)
-			}
-			info.WriteString(elemInfo + "\n")
-		}
-		return tools.NewToolResult(toolCall.ID, info.String())
+	return "", fmt.Errorf("достигнуто максимальное количество итераций (%d). Задача может быть слишком сложной или требовать дополнительной информации", a.opts.MaxIterations)
+}
 
-	case "wait_for_element":
-		var args tools.WaitForElementArgs
-		if err := tools.ParseArguments(toolCall.Function.Arguments, &args); err != nil {
-			return tools.NewToolResult(toolCall.ID, tools.FormatError(err))
-		}
-		timeout := 10 * time.Second
-		if args.Timeout > 0 {
-			timeout = time.Duration(args.Timeout) * time.Second
-		}
-		err := a.browser.WaitForElement(args.Selector, timeout)
-		if err != nil {
-			return tools.NewToolResult(toolCall.ID, tools.FormatError(err))
-		}
-		return tools.NewToolResult(toolCall.ID, fmt.Sprintf("Элемент %s появился", args.Selector))
+func (a *AIAgent) executeTool(ctx context.Context, toolCall ToolCall) (tools.ToolResult, []Image) {
+	env := &tools.Env{
+		Browser: a.browser,
+		RunDir:  a.opts.RunDir,
+		Vision:  a.opts.Vision,
+		Limits:  a.opts.Limits,
+	}
+	result := a.tools.Execute(ctx, env, toolCall.ID, toolCall.Name, toolCall.Arguments)
 
-	case "complete_task":
-		var args tools.CompleteTaskArgs
-		if err := tools.ParseArguments(toolCall.Function.Arguments, &args); err != nil {
-			return tools.NewToolResult(toolCall.ID, tools.FormatError(err))
-		}
-		return tools.NewToolResult(toolCall.ID, fmt.Sprintf("Задача завершена: %s", args.Result))
+	var images []Image
+	for _, img := range env.Images() {
+		images = append(images, Image{MIMEType: img.MIMEType, Data: img.Data})
+	}
+	return result, images
+}
 
-	default:
-		return tools.NewToolResult(toolCall.ID, fmt.Sprintf("Неизвестный инструмент: %s", toolCall.Function.Name))
+// abandonToolCalls закрывает оставшиеся вызовы инструментов ответом об
+// ошибке, чтобы история диалога оставалась корректной для следующей задачи.
+func (a *AIAgent) abandonToolCalls(calls []ToolCall, cause error) {
+	for _, call := range calls {
+		a.conversation = append(a.conversation, Message{
+			Role:       RoleTool,
+			Content:    tools.FormatError(cause),
+			ToolCallID: call.ID,
+		})
 	}
 }
 
-func truncateString(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
+// errTaskCompleted — ответ на вызовы, которые модель запросила после
+// complete_task в том же сообщении.
+var errTaskCompleted = errors.New("задача уже завершена вызовом complete_task, инструмент не выполнялся")
+
+func interruptedError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("превышено время выполнения задачи: %w", err)
 	}
-	return s[:maxLen] + "..."
+	return fmt.Errorf("задача отменена: %w", err)
 }
 
-func (a *AIAgent) GetConversationHistory() []openai.ChatCompletionMessage {
+func (a *AIAgent) GetConversationHistory() []Message {
 	return a.conversation
 }
 
+// ClearHistory начинает диалог заново с системного промпта агента. Шаблон
+// уже проверен в NewAIAgent, поэтому ошибка сборки здесь не ожидается.
 func (a *AIAgent) ClearHistory() {
-	a.conversation = []openai.ChatCompletionMessage{}
 	a.initializeSystemPrompt()
 }
 
-func convertToolsToOpenAI(tools []tools.Tool) []openai.Tool {
-	result := make([]openai.Tool, len(tools))
-	for i, tool := range tools {
-		result[i] = openai.Tool{
-			Type: openai.ToolTypeFunction,
-			Function: &openai.FunctionDefinition{
-				Name:        tool.Function.Name,
-				Description: tool.Function.Description,
-				Parameters:  tool.Function.Parameters,
-			},
+func convertTools(browserTools []tools.Tool) []ToolDefinition {
+	result := make([]ToolDefinition, len(browserTools))
+	for i, tool := range browserTools {
+		result[i] = ToolDefinition{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			Parameters:  tool.Function.Parameters,
 		}
 	}
 	return result
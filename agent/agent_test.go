@@ -0,0 +1,186 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ai-browser-agent/tools"
+)
+
+// newScriptedAgent поднимает ScriptedProvider как OpenAI-совместимый сервер
+// и создает агента без браузера: сценарии тестов не вызывают инструменты,
+// которым он нужен. HTTPProvider используется напрямую, без RetryProvider,
+// чтобы исчерпанный сценарий не повторялся.
+func newScriptedAgent(t *testing.T, maxIterations int, script ...Message) (*AIAgent, *ScriptedProvider) {
+	t.Helper()
+
+	scripted := NewScriptedProvider(script...)
+	server := httptest.NewServer(scripted)
+	t.Cleanup(server.Close)
+
+	agent, err := NewAIAgent(NewHTTPProvider(server.URL, ""), nil, Options{
+		MaxIterations: maxIterations,
+		RunDir:        t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("NewAIAgent: %v", err)
+	}
+	return agent, scripted
+}
+
+// assistantText строит ответ ассистента без вызовов инструментов.
+func assistantText(content string) Message {
+	return Message{Role: RoleAssistant, Content: content}
+}
+
+// assistantToolCall строит ответ ассистента с одним вызовом инструмента.
+// args сериализуется в JSON как аргументы вызова.
+func assistantToolCall(t *testing.T, id, name string, args interface{}) Message {
+	t.Helper()
+
+	arguments, err := json.Marshal(args)
+	if err != nil {
+		t.Fatalf("не удалось сериализовать аргументы %s: %v", name, err)
+	}
+	return Message{
+		Role:      RoleAssistant,
+		ToolCalls: []ToolCall{{ID: id, Name: name, Arguments: string(arguments)}},
+	}
+}
+
+func roles(messages []Message) string {
+	var result []string
+	for _, m := range messages {
+		result = append(result, m.Role)
+	}
+	return strings.Join(result, ",")
+}
+
+func TestExecuteTaskTextAnswer(t *testing.T) {
+	agent, scripted := newScriptedAgent(t, 5, assistantText("Готово"))
+
+	result, err := agent.ExecuteTask(context.Background(), "Скажи готово")
+	if err != nil {
+		t.Fatalf("ExecuteTask: %v", err)
+	}
+	if result != "Готово" {
+		t.Errorf("result = %q, want %q", result, "Готово")
+	}
+
+	requests := scripted.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("requests = %d, want 1", len(requests))
+	}
+	if got := roles(requests[0].Messages); got != "system,user" {
+		t.Errorf("roles = %s, want system,user", got)
+	}
+	if got := requests[0].Messages[1].Content; got != "Скажи готово" {
+		t.Errorf("task message = %q", got)
+	}
+	if len(requests[0].Tools) == 0 {
+		t.Error("request has no tools")
+	}
+}
+
+func TestExecuteTaskCompleteTask(t *testing.T) {
+	agent, scripted := newScriptedAgent(t, 5,
+		assistantToolCall(t, "call_1", "unknown_tool", map[string]string{}),
+		assistantToolCall(t, "call_2", "complete_task", tools.CompleteTaskArgs{Result: "найдено 3 результата"}),
+	)
+
+	result, err := agent.ExecuteTask(context.Background(), "Найди результаты")
+	if err != nil {
+		t.Fatalf("ExecuteTask: %v", err)
+	}
+	if result != "найдено 3 результата" {
+		t.Errorf("result = %q", result)
+	}
+
+	// complete_task завершает задачу без еще одного запроса к модели.
+	requests := scripted.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("requests = %d, want 2", len(requests))
+	}
+	messages := requests[1].Messages
+	if got := roles(messages); got != "system,user,assistant,tool" {
+		t.Fatalf("roles = %s, want system,user,assistant,tool", got)
+	}
+	if got := messages[2].ToolCalls[0].ID; got != "call_1" {
+		t.Errorf("tool call id = %q, want call_1", got)
+	}
+	if got := messages[3].ToolCallID; got != "call_1" {
+		t.Errorf("tool response id = %q, want call_1", got)
+	}
+	if !strings.Contains(messages[3].Content, "Неизвестный инструмент") {
+		t.Errorf("tool response = %q", messages[3].Content)
+	}
+
+	history := agent.GetConversationHistory()
+	if got := history[len(history)-1].ToolCallID; got != "call_2" {
+		t.Errorf("last history message answers %q, want call_2", got)
+	}
+}
+
+func TestExecuteTaskCompleteTaskClosesRemainingCalls(t *testing.T) {
+	completion := assistantToolCall(t, "call_1", "complete_task", tools.CompleteTaskArgs{Result: "готово"})
+	completion.ToolCalls = append(completion.ToolCalls, ToolCall{ID: "call_2", Name: "unknown_tool", Arguments: "{}"})
+	agent, scripted := newScriptedAgent(t, 5, completion, assistantText("Второй ответ"))
+
+	if _, err := agent.ExecuteTask(context.Background(), "Первая задача"); err != nil {
+		t.Fatalf("ExecuteTask: %v", err)
+	}
+	if _, err := agent.ExecuteTask(context.Background(), "Вторая задача"); err != nil {
+		t.Fatalf("ExecuteTask: %v", err)
+	}
+
+	// Каждый вызов из ответа с complete_task должен получить ответ
+	// инструмента до следующей задачи, иначе OpenAI отклонит диалог.
+	messages := scripted.Requests()[1].Messages
+	if got := roles(messages); got != "system,user,assistant,tool,tool,user" {
+		t.Fatalf("roles = %s, want system,user,assistant,tool,tool,user", got)
+	}
+	for i, want := range []string{"call_1", "call_2"} {
+		if got := messages[3+i].ToolCallID; got != want {
+			t.Errorf("tool response %d id = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestExecuteTaskMaxIterations(t *testing.T) {
+	const maxIterations = 3
+	var script []Message
+	for i := 0; i < maxIterations; i++ {
+		script = append(script, assistantToolCall(t, fmt.Sprintf("call_%d", i), "unknown_tool", map[string]string{}))
+	}
+	agent, scripted := newScriptedAgent(t, maxIterations, script...)
+
+	res, err := agent.ExecuteTaskWithOptions(context.Background(), "Бесконечная задача", TaskOptions{})
+	if err == nil || !strings.Contains(err.Error(), "максимальное количество итераций (3)") {
+		t.Fatalf("err = %v, want max iterations error", err)
+	}
+	if res.Iterations != maxIterations {
+		t.Errorf("iterations = %d, want %d", res.Iterations, maxIterations)
+	}
+
+	requests := scripted.Requests()
+	if len(requests) != maxIterations {
+		t.Fatalf("requests = %d, want %d", len(requests), maxIterations)
+	}
+	last := requests[maxIterations-1].Messages
+	if got := roles(last); got != "system,user,assistant,tool,assistant,tool" {
+		t.Errorf("roles = %s", got)
+	}
+	for i, m := range last[2:] {
+		want := fmt.Sprintf("call_%d", i/2)
+		if m.Role == RoleAssistant && m.ToolCalls[0].ID != want {
+			t.Errorf("message %d: tool call id = %q, want %q", i+2, m.ToolCalls[0].ID, want)
+		}
+		if m.Role == RoleTool && m.ToolCallID != want {
+			t.Errorf("message %d: tool response id = %q, want %q", i+2, m.ToolCallID, want)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"ai-browser-agent/tools"
+)
+
+const (
+	// messageOverheadTokens — служебные токены роли и разметки сообщения.
+	messageOverheadTokens = 4
+	// imageTokens — примерная стоимость изображения в токенах.
+	imageTokens = 765
+	// elideMinChars — результаты инструментов короче этого не сокращаются:
+	// выигрыш меньше, чем потеря смысла.
+	elideMinChars = 300
+	// summaryMessageChars — сколько символов каждого сообщения попадает в
+	// запрос на пересказ.
+	summaryMessageChars = 2000
+)
+
+// pageStateTools — инструменты, результат которых описывает текущую
+// страницу. Последний такой результат не сокращается.
+var pageStateTools = map[string]bool{
+	"get_page_content":          true,
+	"get_page_snapshot":         true,
+	"list_interactive_elements": true,
+	"get_elements":              true,
+	"get_page_info":             true,
+}
+
+const summaryPrompt = `Ты сокращаешь историю работы браузерного агента, чтобы она поместилась в контекст модели.
+Перескажи диалог кратко: какие задачи ставил пользователь, какие страницы открывал агент, что он нашел и сделал, какие данные уже собраны и что не получилось.
+Сохрани конкретные адреса, названия, числа и найденные результаты. Не придумывай того, чего нет в диалоге.`
+
+// EstimateTokens оценивает размер сообщения в токенах без обращения к
+// модели: примерно три символа на токен, что ближе к реальности для
+// русского текста, чем обычные четыре для английского.
+func EstimateTokens(m Message) int {
+	chars := utf8.RuneCountInString(m.Content)
+	for _, call := range m.ToolCalls {
+		chars += utf8.RuneCountInString(call.Name) + utf8.RuneCountInString(call.Arguments)
+	}
+	return messageOverheadTokens + (chars+2)/3 + len(m.Images)*imageTokens
+}
+
+func estimateConversation(messages []Message) int {
+	total := 0
+	for _, m := range messages {
+		total += EstimateTokens(m)
+	}
+	return total
+}
+
+// ContextTokens возвращает оценку текущего размера диалога в токенах.
+func (a *AIAgent) ContextTokens() int {
+	return estimateConversation(a.conversation)
+}
+
+// fitContext укладывает диалог в Options.ContextBudget перед запросом к
+// модели. Сначала сокращаются старые результаты инструментов и удаляются
+// старые скриншоты; если этого мало, старые ходы пересказываются моделью.
+// Системный промпт, текущая задача, последний ход и последний снимок
+// страницы не затрагиваются.
+func (a *AIAgent) fitContext(ctx context.Context, res *TaskResult, emit func(Event)) {
+	before := a.ContextTokens()
+	if before <= a.opts.ContextBudget {
+		return
+	}
+
+	pruned := ContextPruned{Time: time.Now(), Iteration: res.Iterations, TokensBefore: before}
+	pruned.Elided = a.elideOldOutputs()
+	if a.ContextTokens() > a.opts.ContextBudget {
+		pruned.Summarized, pruned.Error = a.summarizeOldTurns(ctx, res)
+	}
+	pruned.TokensAfter = a.ContextTokens()
+	emit(pruned)
+}
+
+// currentTurn возвращает индекс последнего ответа модели: с него начинается
+// ход, результаты которого модель еще не видела.
+func (a *AIAgent) currentTurn() int {
+	for i := len(a.conversation) - 1; i > 0; i-- {
+		if a.conversation[i].Role == RoleAssistant {
+			return i
+		}
+	}
+	return len(a.conversation)
+}
+
+// latestPageState возвращает индекс последнего результата инструмента,
+// описывающего страницу, или -1.
+func (a *AIAgent) latestPageState() int {
+	names := a.toolNames()
+	for i := len(a.conversation) - 1; i > 0; i-- {
+		m := a.conversation[i]
+		if m.Role == RoleTool && pageStateTools[names[m.ToolCallID]] {
+			return i
+		}
+	}
+	return -1
+}
+
+func (a *AIAgent) toolNames() map[string]string {
+	names := map[string]string{}
+	for _, m := range a.conversation {
+		for _, call := range m.ToolCalls {
+			names[call.ID] = call.Name
+		}
+	}
+	return names
+}
+
+// elideOldOutputs заменяет длинные результаты инструментов до текущего хода
+// кратким упоминанием и удаляет старые скриншоты. Возвращает число
+// измененных сообщений.
+func (a *AIAgent) elideOldOutputs() int {
+	names := a.toolNames()
+	keepFrom := a.currentTurn()
+	latestState := a.latestPageState()
+
+	elided := 0
+	for i := 1; i < keepFrom; i++ {
+		m := &a.conversation[i]
+		switch {
+		case m.Role == RoleTool && i != latestState && utf8.RuneCountInString(m.Content) > elideMinChars:
+			m.Content = fmt.Sprintf("[результат %s сокращен, было %d символов: %s]",
+				names[m.ToolCallID], utf8.RuneCountInString(m.Content), tools.TruncateString(m.Content, 100))
+			elided++
+		case len(m.Images) > 0:
+			m.Content += fmt.Sprintf(" [скриншоты удалены: %d]", len(m.Images))
+			m.Images = nil
+			elided++
+		}
+	}
+	return elided
+}
+
+// summarizeOldTurns заменяет ходы до последнего снимка страницы (или до
+// текущего хода) пересказом от модели. Текущая задача остается отдельным
+// сообщением. Если пересказ не удался, старые ходы удаляются, чтобы запрос
+// все равно поместился. Возвращает число замененных сообщений и ошибку
+// пересказа.
+func (a *AIAgent) summarizeOldTurns(ctx context.Context, res *TaskResult) (int, string) {
+	end := a.currentTurn()
+	if latest := a.latestPageState(); latest > 0 {
+		// Начало хода, в котором был получен последний снимок страницы.
+		for end = latest; end > 1 && a.conversation[end].Role != RoleAssistant; end-- {
+		}
+	}
+	if end <= 1 {
+		return 0, ""
+	}
+
+	old := a.conversation[1:end]
+	var errText string
+	summary, err := a.summarize(ctx, res, old)
+	if err != nil {
+		errText = err.Error()
+		summary = "[ранняя часть диалога удалена, чтобы уместиться в контекст]"
+	} else {
+		summary = "Краткое содержание предыдущей части диалога:\n" + summary
+	}
+
+	rest := a.conversation[end:]
+	conversation := []Message{a.conversation[0]}
+	if a.taskIndex > 0 && a.taskIndex < end {
+		conversation = append(conversation, a.conversation[a.taskIndex])
+	}
+	conversation = append(conversation, Message{Role: RoleUser, Content: summary})
+	if a.taskIndex >= end {
+		a.taskIndex -= end - len(conversation)
+	} else if a.taskIndex > 0 {
+		a.taskIndex = 1
+	}
+	a.conversation = append(conversation, rest...)
+
+	return len(old), errText
+}
+
+func (a *AIAgent) summarize(ctx context.Context, res *TaskResult, messages []Message) (string, error) {
+	var transcript strings.Builder
+	for _, m := range messages {
+		fmt.Fprintf(&transcript, "[%s] %s\n", m.Role, tools.TruncateString(m.Content, summaryMessageChars))
+		for _, call := range m.ToolCalls {
+			fmt.Fprintf(&transcript, "[вызов] %s %s\n", call.Name, tools.TruncateString(call.Arguments, summaryMessageChars))
+		}
+	}
+
+	resp, err := a.provider.Chat(ctx, ChatRequest{
+		Model: a.opts.Model,
+		Messages: []Message{
+			{Role: RoleSystem, Content: summaryPrompt},
+			{Role: RoleUser, Content: transcript.String()},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("не удалось пересказать диалог: %w", err)
+	}
+	model := a.opts.Model
+	if resp.Model != "" {
+		model = resp.Model
+	}
+	a.recordUsage(res, model, resp.Usage)
+	if strings.TrimSpace(resp.Message.Content) == "" {
+		return "", fmt.Errorf("не удалось пересказать диалог: модель вернула пустой ответ")
+	}
+	return resp.Message.Content, nil
+}
@@ -0,0 +1,167 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ErrorKind — класс ошибки запроса к модели. От него зависит, повторять
+// ли запрос и переходить ли на запасную модель.
+type ErrorKind string
+
+const (
+	// ErrorRateLimit — превышен лимит запросов или токенов (429).
+	ErrorRateLimit ErrorKind = "rate_limit"
+	// ErrorServer — временная ошибка на стороне сервера (5xx).
+	ErrorServer ErrorKind = "server"
+	// ErrorNetwork — запрос не дошел до сервера или ответ не получен.
+	ErrorNetwork ErrorKind = "network"
+	// ErrorModelUnavailable — модель не существует или недоступна с этим
+	// ключом; повтор не поможет, но запасная модель может.
+	ErrorModelUnavailable ErrorKind = "model_unavailable"
+	// ErrorAuth — неверный API ключ или нет доступа (401, 403).
+	ErrorAuth ErrorKind = "auth"
+	// ErrorInvalidRequest — сервер отклонил запрос, например из-за размера
+	// контекста (400).
+	ErrorInvalidRequest ErrorKind = "invalid_request"
+	ErrorUnknown        ErrorKind = "unknown"
+)
+
+// ProviderError — классифицированная ошибка провайдера модели.
+type ProviderError struct {
+	Kind       ErrorKind
+	Model      string
+	StatusCode int
+	// RetryAfter — пауза перед повтором, которую запросил сервер.
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *ProviderError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "модель %s: %s", e.Model, kindDescriptions[e.Kind])
+	if e.StatusCode != 0 {
+		fmt.Fprintf(&b, " (статус %d)", e.StatusCode)
+	}
+	if e.Err != nil {
+		fmt.Fprintf(&b, ": %v", e.Err)
+	}
+	return b.String()
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary сообщает, что запрос к той же модели имеет смысл повторить.
+func (e *ProviderError) Temporary() bool {
+	switch e.Kind {
+	case ErrorRateLimit, ErrorServer, ErrorNetwork:
+		return true
+	}
+	return false
+}
+
+var kindDescriptions = map[ErrorKind]string{
+	ErrorRateLimit:        "превышен лимит запросов",
+	ErrorServer:           "ошибка сервера",
+	ErrorNetwork:          "сетевая ошибка",
+	ErrorModelUnavailable: "модель недоступна",
+	ErrorAuth:             "ошибка авторизации",
+	ErrorInvalidRequest:   "некорректный запрос",
+	ErrorUnknown:          "ошибка запроса",
+}
+
+// classifyStatus определяет класс ошибки по HTTP статусу и тексту ответа.
+// Недоступную модель серверы сообщают по-разному: 404, 400 или 403 с
+// кодом model_not_found, поэтому для ошибок клиента проверяется и текст.
+func classifyStatus(status int, message string) ErrorKind {
+	lower := strings.ToLower(message)
+	clientError := status < 500 && status != http.StatusTooManyRequests
+	if clientError && (strings.Contains(lower, "model_not_found") ||
+		(strings.Contains(lower, "model") && (strings.Contains(lower, "does not exist") || strings.Contains(lower, "not found")))) {
+		return ErrorModelUnavailable
+	}
+
+	switch {
+	case status == http.StatusTooManyRequests:
+		return ErrorRateLimit
+	case status == http.StatusNotFound:
+		return ErrorModelUnavailable
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrorAuth
+	case status == http.StatusRequestTimeout || status >= 500:
+		return ErrorServer
+	case status >= 400:
+		return ErrorInvalidRequest
+	}
+	return ErrorUnknown
+}
+
+// classifyError превращает ошибку провайдера в ProviderError. Отмена
+// контекста возвращается как есть: это не ошибка модели.
+func classifyError(ctx context.Context, model string, err error, retryAfter time.Duration) error {
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return err
+	}
+
+	result := &ProviderError{Kind: ErrorUnknown, Model: model, RetryAfter: retryAfter, Err: err}
+
+	var apiErr *openai.APIError
+	var requestErr *openai.RequestError
+	switch {
+	case errors.As(err, &apiErr):
+		result.StatusCode = apiErr.HTTPStatusCode
+		result.Kind = classifyStatus(apiErr.HTTPStatusCode, fmt.Sprintf("%v %s", apiErr.Code, apiErr.Message))
+	case errors.As(err, &requestErr):
+		result.StatusCode = requestErr.HTTPStatusCode
+		result.Kind = classifyStatus(requestErr.HTTPStatusCode, string(requestErr.Body))
+	case errors.Is(err, context.DeadlineExceeded) || isNetworkError(err):
+		result.Kind = ErrorNetwork
+	}
+	return result
+}
+
+// isNetworkError распознает ошибки транспорта: http.Client оборачивает их
+// в *url.Error.
+func isNetworkError(err error) bool {
+	var urlErr *url.Error
+	var netErr net.Error
+	return errors.As(err, &urlErr) || errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// parseRetryAfter читает паузу из заголовков Retry-After (секунды или дата)
+// и retry-after-ms, который присылает OpenAI.
+func parseRetryAfter(header http.Header) time.Duration {
+	if ms, err := strconv.ParseFloat(header.Get("Retry-After-Ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
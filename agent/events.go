@@ -0,0 +1,147 @@
+package agent
+
+import (
+	"time"
+
+	"ai-browser-agent/browser"
+)
+
+// Event — событие выполнения задачи. Конкретный тип события определяет
+// его поля; EventName возвращает имя события для журналов и потоков.
+type Event interface {
+	EventName() string
+}
+
+// Observer получает события агента. Вызовы происходят синхронно из цикла
+// выполнения задачи, поэтому обработчик не должен надолго блокироваться.
+type Observer interface {
+	OnEvent(Event)
+}
+
+// ObserverFunc позволяет использовать функцию как Observer.
+type ObserverFunc func(Event)
+
+func (f ObserverFunc) OnEvent(e Event) {
+	f(e)
+}
+
+// ObserverFactory создает наблюдателя для конкретного агента: с его
+// браузером и каталогом запуска. Так пул и сервер, создающие агента на
+// каждую задачу, получают для каждого свой экземпляр наблюдателя.
+type ObserverFactory func(bm *browser.BrowserManager, runDir string) Observer
+
+type TaskStarted struct {
+	Time          time.Time `json:"time"`
+	Task          string    `json:"task"`
+	MaxIterations int       `json:"max_iterations"`
+}
+
+type IterationStarted struct {
+	Time          time.Time `json:"time"`
+	Iteration     int       `json:"iteration"`
+	MaxIterations int       `json:"max_iterations"`
+}
+
+// ContextPruned — диалог не помещался в бюджет и был сокращен: Elided
+// результатов инструментов и скриншотов сокращено, Summarized сообщений
+// заменено пересказом. Error — почему не удался пересказ.
+type ContextPruned struct {
+	Time         time.Time `json:"time"`
+	Iteration    int       `json:"iteration"`
+	TokensBefore int       `json:"tokens_before"`
+	TokensAfter  int       `json:"tokens_after"`
+	Elided       int       `json:"elided"`
+	Summarized   int       `json:"summarized"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// ModelRequested — запрос к модели. Messages содержит диалог целиком,
+// включая изображения, и доступен только наблюдателям в процессе: в JSON
+// событие передает лишь число сообщений.
+type ModelRequested struct {
+	Time         time.Time `json:"time"`
+	Iteration    int       `json:"iteration"`
+	Model        string    `json:"model"`
+	Messages     []Message `json:"-"`
+	MessageCount int       `json:"messages"`
+}
+
+// ModelRetried — запрос к модели не удался и будет повторен: через Delay
+// к той же модели или сразу к запасной модели Next.
+type ModelRetried struct {
+	Time      time.Time     `json:"time"`
+	Iteration int           `json:"iteration"`
+	Model     string        `json:"model"`
+	Next      string        `json:"next"`
+	Attempt   int           `json:"attempt"`
+	Delay     time.Duration `json:"delay"`
+	Error     string        `json:"error"`
+}
+
+// ModelResponded — ответ модели: текст и запрошенные вызовы инструментов.
+// Model — модель, которая ответила.
+type ModelResponded struct {
+	Time      time.Time  `json:"time"`
+	Iteration int        `json:"iteration"`
+	Model     string     `json:"model"`
+	Text      string     `json:"text,omitempty"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	Usage     Usage      `json:"usage"`
+	// Cost — стоимость запроса в долларах, ноль для модели без цены.
+	Cost     float64       `json:"cost"`
+	Duration time.Duration `json:"duration"`
+}
+
+type ToolCalled struct {
+	Time      time.Time `json:"time"`
+	Iteration int       `json:"iteration"`
+	Call      ToolCall  `json:"call"`
+}
+
+type ToolFinished struct {
+	Time      time.Time `json:"time"`
+	Iteration int       `json:"iteration"`
+	Call      ToolCall  `json:"call"`
+	Result    string    `json:"result"`
+	IsError   bool      `json:"is_error,omitempty"`
+	// Selector — CSS селектор элемента, если модель указала его по ref.
+	Selector string        `json:"selector,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// TaskFinished завершает поток событий задачи. Error пуст, если задача
+// выполнена.
+type TaskFinished struct {
+	Time       time.Time     `json:"time"`
+	Result     string        `json:"result,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Iterations int           `json:"iterations"`
+	Duration   time.Duration `json:"duration"`
+	Usage      Usage         `json:"usage"`
+	Cost       float64       `json:"cost"`
+	Unpriced   bool          `json:"unpriced,omitempty"`
+}
+
+func (TaskStarted) EventName() string      { return "task_started" }
+func (IterationStarted) EventName() string { return "iteration_started" }
+func (ContextPruned) EventName() string    { return "context_pruned" }
+func (ModelRequested) EventName() string   { return "model_requested" }
+func (ModelRetried) EventName() string     { return "model_retried" }
+func (ModelResponded) EventName() string   { return "model_responded" }
+func (ToolCalled) EventName() string       { return "tool_called" }
+func (ToolFinished) EventName() string     { return "tool_finished" }
+func (TaskFinished) EventName() string     { return "task_finished" }
+
+// AddObserver подписывает o на события всех задач агента.
+func (a *AIAgent) AddObserver(o Observer) {
+	a.observers = append(a.observers, o)
+}
+
+func (a *AIAgent) emit(taskObserver Observer, e Event) {
+	for _, o := range a.observers {
+		o.OnEvent(e)
+	}
+	if taskObserver != nil {
+		taskObserver.OnEvent(e)
+	}
+}
@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// ScriptedProvider — детерминированная замена модели: отдает заранее
+// записанные ответы ассистента по порядку и запоминает полученные запросы.
+// Реализует http.Handler с OpenAI-совместимым протоколом, поэтому его можно
+// поднять через httptest.NewServer и подключить к HTTPProvider.
+type ScriptedProvider struct {
+	// Repeat заставляет повторять последний ответ после окончания сценария.
+	Repeat bool
+
+	mu       sync.Mutex
+	script   []Message
+	next     int
+	requests []ChatRequest
+}
+
+func NewScriptedProvider(script ...Message) *ScriptedProvider {
+	return &ScriptedProvider{script: script}
+}
+
+// LoadScriptedProvider читает сценарий из JSON файла с массивом сообщений.
+func LoadScriptedProvider(path string) (*ScriptedProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать сценарий %s: %w", path, err)
+	}
+
+	var script []Message
+	if err := json.Unmarshal(data, &script); err != nil {
+		return nil, fmt.Errorf("не удалось разобрать сценарий %s: %w", path, err)
+	}
+
+	return NewScriptedProvider(script...), nil
+}
+
+func (p *ScriptedProvider) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return ChatResponse{}, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.requests = append(p.requests, req)
+
+	if p.next >= len(p.script) {
+		if !p.Repeat || len(p.script) == 0 {
+			return ChatResponse{}, fmt.Errorf("сценарий исчерпан после %d ответов", len(p.script))
+		}
+		return ChatResponse{Message: p.script[len(p.script)-1]}, nil
+	}
+
+	msg := p.script[p.next]
+	p.next++
+	return ChatResponse{Message: msg}, nil
+}
+
+// Requests возвращает копию всех запросов, полученных провайдером.
+func (p *ScriptedProvider) Requests() []ChatRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]ChatRequest(nil), p.requests...)
+}
+
+func (p *ScriptedProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var wireReq wireRequest
+	if err := json.NewDecoder(r.Body).Decode(&wireReq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := ChatRequest{
+		Model:       wireReq.Model,
+		Temperature: wireReq.Temperature,
+	}
+	for _, msg := range wireReq.Messages {
+		req.Messages = append(req.Messages, fromWireMessage(msg))
+	}
+	for _, tool := range wireReq.Tools {
+		req.Tools = append(req.Tools, tool.Function)
+	}
+
+	resp, err := p.Chat(r.Context(), req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(wireResponse{
+		Object:  "chat.completion",
+		Model:   req.Model,
+		Choices: []wireChoice{{Message: toWireMessage(resp.Message)}},
+		Usage:   resp.Usage,
+	})
+}
@@ -0,0 +1,93 @@
+package agent
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScriptedProviderOverHTTP(t *testing.T) {
+	scripted := NewScriptedProvider(
+		Message{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "call_1", Name: "navigate", Arguments: `{"url":"example.com"}`}}},
+		assistantText("Готово"),
+	)
+	server := httptest.NewServer(scripted)
+	defer server.Close()
+	provider := NewHTTPProvider(server.URL, "")
+
+	req := ChatRequest{
+		Model:    "scripted",
+		Messages: []Message{{Role: RoleUser, Content: "Открой example.com"}},
+		Tools:    []ToolDefinition{{Name: "navigate", Parameters: map[string]interface{}{"type": "object"}}},
+	}
+
+	resp, err := provider.Chat(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if len(resp.Message.ToolCalls) != 1 || resp.Message.ToolCalls[0].ID != "call_1" || resp.Message.ToolCalls[0].Arguments != `{"url":"example.com"}` {
+		t.Errorf("tool calls = %+v", resp.Message.ToolCalls)
+	}
+
+	resp, err = provider.Chat(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if resp.Message.Content != "Готово" {
+		t.Errorf("content = %q, want Готово", resp.Message.Content)
+	}
+
+	if _, err := provider.Chat(context.Background(), req); err == nil || !strings.Contains(err.Error(), "сценарий исчерпан") {
+		t.Errorf("err = %v, want exhausted script", err)
+	}
+
+	requests := scripted.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("requests = %d, want 3", len(requests))
+	}
+	if requests[0].Model != "scripted" || requests[0].Messages[0].Content != "Открой example.com" || requests[0].Tools[0].Name != "navigate" {
+		t.Errorf("request = %+v", requests[0])
+	}
+}
+
+func TestScriptedProviderRepeat(t *testing.T) {
+	scripted := NewScriptedProvider(assistantText("первый"), assistantText("последний"))
+	scripted.Repeat = true
+
+	for i, want := range []string{"первый", "последний", "последний"} {
+		resp, err := scripted.Chat(context.Background(), ChatRequest{})
+		if err != nil {
+			t.Fatalf("Chat %d: %v", i, err)
+		}
+		if resp.Message.Content != want {
+			t.Errorf("Chat %d = %q, want %q", i, resp.Message.Content, want)
+		}
+	}
+}
+
+func TestLoadScriptedProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.json")
+	script := `[{"role": "assistant", "tool_calls": [{"id": "call_1", "name": "complete_task", "arguments": "{\"result\": \"ok\"}"}]}]`
+	if err := os.WriteFile(path, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	scripted, err := LoadScriptedProvider(path)
+	if err != nil {
+		t.Fatalf("LoadScriptedProvider: %v", err)
+	}
+	resp, err := scripted.Chat(context.Background(), ChatRequest{})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if call := resp.Message.ToolCalls[0]; call.Name != "complete_task" || call.Arguments != `{"result": "ok"}` {
+		t.Errorf("tool call = %+v", call)
+	}
+
+	if _, err := LoadScriptedProvider(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadScriptedProvider of a missing file: want error")
+	}
+}
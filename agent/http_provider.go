@@ -0,0 +1,247 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// HTTPProvider обращается к любому серверу с OpenAI-совместимым
+// эндпоинтом /chat/completions (локальные модели, прокси, тестовые заглушки).
+type HTTPProvider struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+func NewHTTPProvider(baseURL, apiKey string) *HTTPProvider {
+	return &HTTPProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		client:  http.DefaultClient,
+	}
+}
+
+type wireFunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+type wireToolCall struct {
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function wireFunctionCall `json:"function"`
+}
+
+// wireContent — поле content, которое в протоколе бывает либо строкой,
+// либо массивом частей (текст и изображения).
+type wireContent struct {
+	Text   string
+	Images []Image
+}
+
+type wireImageURL struct {
+	URL string `json:"url"`
+}
+
+type wireContentPart struct {
+	Type     string        `json:"type"`
+	Text     string        `json:"text,omitempty"`
+	ImageURL *wireImageURL `json:"image_url,omitempty"`
+}
+
+func (c wireContent) MarshalJSON() ([]byte, error) {
+	if len(c.Images) == 0 {
+		return json.Marshal(c.Text)
+	}
+
+	parts := []wireContentPart{{Type: "text", Text: c.Text}}
+	for _, img := range c.Images {
+		parts = append(parts, wireContentPart{Type: "image_url", ImageURL: &wireImageURL{URL: img.DataURL()}})
+	}
+	return json.Marshal(parts)
+}
+
+func (c *wireContent) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(data, &c.Text); err == nil {
+		return nil
+	}
+
+	var parts []wireContentPart
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return err
+	}
+
+	var text []string
+	for _, part := range parts {
+		switch part.Type {
+		case "text":
+			text = append(text, part.Text)
+		case "image_url":
+			if part.ImageURL == nil {
+				continue
+			}
+			img, err := parseDataURL(part.ImageURL.URL)
+			if err != nil {
+				return err
+			}
+			c.Images = append(c.Images, img)
+		}
+	}
+	c.Text = strings.Join(text, "\n")
+	return nil
+}
+
+func parseDataURL(url string) (Image, error) {
+	header, payload, ok := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+	if !ok || !strings.HasPrefix(url, "data:") || !strings.HasSuffix(header, ";base64") {
+		return Image{}, fmt.Errorf("поддерживаются только изображения в формате data URL")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return Image{}, fmt.Errorf("некорректное изображение: %w", err)
+	}
+	return Image{MIMEType: strings.TrimSuffix(header, ";base64"), Data: data}, nil
+}
+
+type wireMessage struct {
+	Role       string         `json:"role"`
+	Content    wireContent    `json:"content"`
+	ToolCalls  []wireToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+}
+
+type wireTool struct {
+	Type     string         `json:"type"`
+	Function ToolDefinition `json:"function"`
+}
+
+type wireRequest struct {
+	Model       string        `json:"model"`
+	Messages    []wireMessage `json:"messages"`
+	Tools       []wireTool    `json:"tools,omitempty"`
+	Temperature float32       `json:"temperature"`
+}
+
+type wireChoice struct {
+	Index   int         `json:"index"`
+	Message wireMessage `json:"message"`
+}
+
+type wireResponse struct {
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Model   string       `json:"model"`
+	Choices []wireChoice `json:"choices"`
+	Usage   Usage        `json:"usage"`
+}
+
+func (p *HTTPProvider) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	body, err := json.Marshal(toWireRequest(req))
+	if err != nil {
+		return ChatResponse{}, fmt.Errorf("не удалось сериализовать запрос: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	httpResp, err := p.client.Do(httpReq)
+	if err != nil {
+		return ChatResponse{}, classifyError(ctx, req.Model, err, 0)
+	}
+	defer httpResp.Body.Close()
+
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return ChatResponse{}, classifyError(ctx, req.Model, fmt.Errorf("не удалось прочитать ответ: %w", err), 0)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		body := strings.TrimSpace(string(data))
+		return ChatResponse{}, &ProviderError{
+			Kind:       classifyStatus(httpResp.StatusCode, body),
+			Model:      req.Model,
+			StatusCode: httpResp.StatusCode,
+			RetryAfter: parseRetryAfter(httpResp.Header),
+			Err:        errors.New(body),
+		}
+	}
+
+	var resp wireResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return ChatResponse{}, fmt.Errorf("не удалось разобрать ответ: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return ChatResponse{}, fmt.Errorf("модель вернула пустой ответ")
+	}
+
+	return ChatResponse{
+		Message: fromWireMessage(resp.Choices[0].Message),
+		Usage:   resp.Usage,
+	}, nil
+}
+
+func toWireRequest(req ChatRequest) wireRequest {
+	result := wireRequest{
+		Model:       req.Model,
+		Temperature: req.Temperature,
+	}
+	for _, msg := range req.Messages {
+		result.Messages = append(result.Messages, toWireMessage(msg))
+	}
+	for _, tool := range req.Tools {
+		result.Tools = append(result.Tools, wireTool{Type: "function", Function: tool})
+	}
+	return result
+}
+
+func toWireMessage(msg Message) wireMessage {
+	result := wireMessage{
+		Role:       msg.Role,
+		Content:    wireContent{Text: msg.Content, Images: msg.Images},
+		ToolCallID: msg.ToolCallID,
+	}
+	for _, call := range msg.ToolCalls {
+		result.ToolCalls = append(result.ToolCalls, wireToolCall{
+			ID:   call.ID,
+			Type: "function",
+			Function: wireFunctionCall{
+				Name:      call.Name,
+				Arguments: call.Arguments,
+			},
+		})
+	}
+	return result
+}
+
+func fromWireMessage(msg wireMessage) Message {
+	result := Message{
+		Role:       msg.Role,
+		Content:    msg.Content.Text,
+		ToolCallID: msg.ToolCallID,
+		Images:     msg.Content.Images,
+	}
+	for _, call := range msg.ToolCalls {
+		result.ToolCalls = append(result.ToolCalls, ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+	return result
+}
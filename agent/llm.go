@@ -0,0 +1,119 @@
+package agent
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+)
+
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// Message — сообщение диалога, не привязанное к конкретному SDK.
+type Message struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content,omitempty"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+	Images     []Image    `json:"images,omitempty"`
+}
+
+// Image — изображение, передаваемое модели вместе с текстом сообщения.
+type Image struct {
+	MIMEType string `json:"mime_type"`
+	Data     []byte `json:"data"`
+}
+
+func (img Image) DataURL() string {
+	return "data:" + img.MIMEType + ";base64," + base64.StdEncoding.EncodeToString(img.Data)
+}
+
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+type ToolDefinition struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+type ChatRequest struct {
+	Model       string
+	Messages    []Message
+	Tools       []ToolDefinition
+	Temperature float32
+}
+
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type ChatResponse struct {
+	Message Message
+	Usage   Usage
+	// Model — модель, которая ответила; пусто означает запрошенную модель.
+	Model string
+}
+
+// LLMProvider — модель, умеющая отвечать на диалог с вызовами инструментов.
+type LLMProvider interface {
+	Chat(ctx context.Context, req ChatRequest) (ChatResponse, error)
+}
+
+const (
+	ProviderOpenAI = "openai"
+	ProviderHTTP   = "http"
+)
+
+// ProviderOptions описывает подключение к модели. Пустой Type означает
+// ProviderHTTP, если задан BaseURL, и ProviderOpenAI в остальных случаях.
+type ProviderOptions struct {
+	Type    string
+	BaseURL string
+	APIKey  string
+	// Retry — повторы после временных ошибок и запасные модели.
+	Retry RetryOptions
+}
+
+// NewProvider создает провайдер по opts и оборачивает его в RetryProvider.
+func NewProvider(opts ProviderOptions) (LLMProvider, error) {
+	provider, err := newProvider(opts)
+	if err != nil {
+		return nil, err
+	}
+	return NewRetryProvider(provider, opts.Retry), nil
+}
+
+func newProvider(opts ProviderOptions) (LLMProvider, error) {
+	providerType := opts.Type
+	if providerType == "" {
+		providerType = ProviderOpenAI
+		if opts.BaseURL != "" {
+			providerType = ProviderHTTP
+		}
+	}
+
+	switch providerType {
+	case ProviderOpenAI:
+		if opts.APIKey == "" {
+			return nil, fmt.Errorf("не задан API ключ OpenAI. Установите OPENAI_API_KEY или llm.api_key в конфигурации")
+		}
+		return NewOpenAIProvider(opts.APIKey), nil
+	case ProviderHTTP:
+		if opts.BaseURL == "" {
+			return nil, fmt.Errorf("для провайдера %s нужен адрес сервера (OPENAI_BASE_URL или llm.base_url)", ProviderHTTP)
+		}
+		return NewHTTPProvider(opts.BaseURL, opts.APIKey), nil
+	default:
+		return nil, fmt.Errorf("неизвестный провайдер %q, ожидается %s или %s", opts.Type, ProviderOpenAI, ProviderHTTP)
+	}
+}
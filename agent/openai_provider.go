@@ -0,0 +1,128 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+type OpenAIProvider struct {
+	client *openai.Client
+}
+
+func NewOpenAIProvider(apiKey string) *OpenAIProvider {
+	config := openai.DefaultConfig(apiKey)
+	config.HTTPClient = retryAfterClient{client: &http.Client{}}
+	return &OpenAIProvider{client: openai.NewClientWithConfig(config)}
+}
+
+// retryAfterClient запоминает паузу из заголовков ответа в retryAfterKey
+// контекста запроса: go-openai не передает заголовки вместе с ошибкой.
+type retryAfterClient struct {
+	client *http.Client
+}
+
+type retryAfterKey struct{}
+
+func (c retryAfterClient) Do(req *http.Request) (*http.Response, error) {
+	resp, err := c.client.Do(req)
+	if resp != nil {
+		if retryAfter, ok := req.Context().Value(retryAfterKey{}).(*time.Duration); ok {
+			*retryAfter = parseRetryAfter(resp.Header)
+		}
+	}
+	return resp, err
+}
+
+func (p *OpenAIProvider) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	var retryAfter time.Duration
+	resp, err := p.client.CreateChatCompletion(context.WithValue(ctx, retryAfterKey{}, &retryAfter), openai.ChatCompletionRequest{
+		Model:       req.Model,
+		Messages:    toOpenAIMessages(req.Messages),
+		Tools:       toOpenAITools(req.Tools),
+		Temperature: req.Temperature,
+	})
+	if err != nil {
+		return ChatResponse{}, classifyError(ctx, req.Model, err, retryAfter)
+	}
+	if len(resp.Choices) == 0 {
+		return ChatResponse{}, fmt.Errorf("модель вернула пустой ответ")
+	}
+
+	return ChatResponse{
+		Message: fromOpenAIMessage(resp.Choices[0].Message),
+		Usage: Usage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+		},
+	}, nil
+}
+
+func toOpenAIMessages(messages []Message) []openai.ChatCompletionMessage {
+	result := make([]openai.ChatCompletionMessage, len(messages))
+	for i, msg := range messages {
+		result[i] = openai.ChatCompletionMessage{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			ToolCallID: msg.ToolCallID,
+		}
+		if len(msg.Images) > 0 {
+			result[i].Content = ""
+			result[i].MultiContent = []openai.ChatMessagePart{
+				{Type: openai.ChatMessagePartTypeText, Text: msg.Content},
+			}
+			for _, img := range msg.Images {
+				result[i].MultiContent = append(result[i].MultiContent, openai.ChatMessagePart{
+					Type:     openai.ChatMessagePartTypeImageURL,
+					ImageURL: &openai.ChatMessageImageURL{URL: img.DataURL()},
+				})
+			}
+		}
+		for _, call := range msg.ToolCalls {
+			result[i].ToolCalls = append(result[i].ToolCalls, openai.ToolCall{
+				ID:   call.ID,
+				Type: openai.ToolTypeFunction,
+				Function: openai.FunctionCall{
+					Name:      call.Name,
+					Arguments: call.Arguments,
+				},
+			})
+		}
+	}
+	return result
+}
+
+func fromOpenAIMessage(msg openai.ChatCompletionMessage) Message {
+	result := Message{
+		Role:       msg.Role,
+		Content:    msg.Content,
+		ToolCallID: msg.ToolCallID,
+	}
+	for _, call := range msg.ToolCalls {
+		result.ToolCalls = append(result.ToolCalls, ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+	return result
+}
+
+func toOpenAITools(tools []ToolDefinition) []openai.Tool {
+	result := make([]openai.Tool, len(tools))
+	for i, tool := range tools {
+		result[i] = openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		}
+	}
+	return result
+}
@@ -0,0 +1,97 @@
+package agent
+
+import (
+	_ "embed"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//go:embed prompts/system.tmpl
+var defaultSystemPrompt string
+
+const defaultLanguage = "русский"
+
+// PromptData — данные, доступные в шаблоне системного промпта.
+type PromptData struct {
+	// Date — текущая дата в формате 2006-01-02.
+	Date     string
+	Tools    []ToolDefinition
+	Language string
+	Hints    []SiteHint
+}
+
+type SiteHint struct {
+	Site string
+	Hint string
+}
+
+// TaskOptions переопределяют настройки промпта для одной задачи. Пустые
+// поля означают значения агента.
+type TaskOptions struct {
+	// SystemPrompt — текст шаблона системного промпта вместо шаблона агента.
+	SystemPrompt string
+	Language     string
+	// SiteHints дополняют подсказки агента; подсказка для того же сайта
+	// заменяет подсказку агента.
+	SiteHints map[string]string
+	// Observer, если задан, получает события только этой задачи в
+	// дополнение к наблюдателям агента.
+	Observer Observer
+}
+
+// ParseSystemPrompt разбирает шаблон системного промпта (text/template с
+// полями PromptData).
+func ParseSystemPrompt(text string) (*template.Template, error) {
+	tmpl, err := template.New("system").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный шаблон системного промпта: %w", err)
+	}
+	return tmpl, nil
+}
+
+// renderSystemPrompt собирает системный промпт для задачи с учетом
+// переопределений из opts.
+func (a *AIAgent) renderSystemPrompt(opts TaskOptions) (string, error) {
+	tmpl := a.systemPrompt
+	if opts.SystemPrompt != "" {
+		var err error
+		tmpl, err = ParseSystemPrompt(opts.SystemPrompt)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	language := opts.Language
+	if language == "" {
+		language = a.opts.Language
+	}
+
+	hints := map[string]string{}
+	for site, hint := range a.opts.SiteHints {
+		hints[site] = hint
+	}
+	for site, hint := range opts.SiteHints {
+		hints[site] = hint
+	}
+
+	data := PromptData{
+		Date:     time.Now().Format("2006-01-02"),
+		Tools:    convertTools(a.tools.Tools()),
+		Language: language,
+	}
+	for site, hint := range hints {
+		data.Hints = append(data.Hints, SiteHint{Site: site, Hint: hint})
+	}
+	sort.Slice(data.Hints, func(i, j int) bool {
+		return data.Hints[i].Site < data.Hints[j].Site
+	})
+
+	var prompt strings.Builder
+	if err := tmpl.Execute(&prompt, data); err != nil {
+		return "", fmt.Errorf("не удалось собрать системный промпт: %w", err)
+	}
+	return prompt.String(), nil
+}
@@ -0,0 +1,102 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"ai-browser-agent/tools"
+)
+
+// Форматы вывода хода выполнения задачи для NewRenderer.
+const (
+	OutputConsole = "console"
+	OutputJSON    = "json"
+	OutputNone    = "none"
+)
+
+// NewRenderer создает наблюдателя, который выводит события в w в формате
+// format. Для OutputNone возвращается nil.
+func NewRenderer(format string, w io.Writer) (Observer, error) {
+	switch format {
+	case OutputConsole:
+		return NewConsoleRenderer(w), nil
+	case OutputJSON:
+		return NewJSONRenderer(w), nil
+	case OutputNone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("неизвестный формат вывода %q, ожидается %s, %s или %s", format, OutputConsole, OutputJSON, OutputNone)
+	}
+}
+
+// ConsoleRenderer печатает ход выполнения задачи для человека.
+type ConsoleRenderer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewConsoleRenderer(w io.Writer) *ConsoleRenderer {
+	return &ConsoleRenderer{w: w}
+}
+
+func (r *ConsoleRenderer) OnEvent(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch e := e.(type) {
+	case TaskStarted:
+		fmt.Fprintf(r.w, " Задача: %s\n", e.Task)
+		fmt.Fprintln(r.w, "Агент начинает выполнение...")
+		fmt.Fprintln(r.w)
+	case IterationStarted:
+		fmt.Fprintf(r.w, " Итерация %d/%d\n", e.Iteration, e.MaxIterations)
+	case ContextPruned:
+		fmt.Fprintf(r.w, " Контекст сокращен: %d → %d токенов\n", e.TokensBefore, e.TokensAfter)
+		if e.Error != "" {
+			fmt.Fprintf(r.w, " %s\n", e.Error)
+		}
+	case ModelRetried:
+		if e.Next != e.Model {
+			fmt.Fprintf(r.w, " Модель %s не ответила, переход на %s: %s\n", e.Model, e.Next, e.Error)
+		} else {
+			fmt.Fprintf(r.w, " Повтор запроса к модели через %s: %s\n", e.Delay.Round(100*time.Millisecond), e.Error)
+		}
+	case ModelResponded:
+		if len(e.ToolCalls) == 0 {
+			fmt.Fprintln(r.w, " Агент завершил задачу без вызовов инструментов")
+		}
+	case ToolCalled:
+		fmt.Fprintf(r.w, " Вызов инструмента: %s\n", e.Call.Name)
+	case ToolFinished:
+		fmt.Fprintf(r.w, " Результат: %s\n\n", tools.TruncateString(e.Result, 200))
+	case TaskFinished:
+		fmt.Fprintf(r.w, " Токены: %d (запрос %d, ответ %d), стоимость: %s\n",
+			e.Usage.TotalTokens, e.Usage.PromptTokens, e.Usage.CompletionTokens, FormatCost(e.Cost, e.Unpriced))
+	}
+}
+
+// JSONRenderer пишет события в формате JSON Lines: по объекту
+// {"type": ..., "data": ...} на строку, как в потоке событий HTTP API.
+type JSONRenderer struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewJSONRenderer(w io.Writer) *JSONRenderer {
+	return &JSONRenderer{enc: json.NewEncoder(w)}
+}
+
+type jsonEvent struct {
+	Type string `json:"type"`
+	Data Event  `json:"data"`
+}
+
+func (r *JSONRenderer) OnEvent(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.enc.Encode(jsonEvent{Type: e.EventName(), Data: e})
+}
@@ -0,0 +1,141 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"ai-browser-agent/internal/timeutil"
+)
+
+// RetryOptions — повторы запросов к модели. Нулевые BaseDelay и MaxDelay
+// заменяются значениями по умолчанию, нулевые MaxRetries и Budget
+// отключают повторы.
+type RetryOptions struct {
+	// MaxRetries — сколько раз повторять запрос к одной модели после
+	// временной ошибки: лимита запросов, ошибки сервера или сети.
+	MaxRetries int
+	// BaseDelay — пауза перед первым повтором, дальше она удваивается, но
+	// не превышает MaxDelay. К паузе добавляется случайный разброс, чтобы
+	// параллельные задачи не повторяли запросы одновременно.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Budget — сколько всего можно ждать между повторами одного запроса,
+	// включая паузы, которые запросил сервер через Retry-After.
+	Budget time.Duration
+	// FallbackModels пробуются по порядку, если основная модель недоступна
+	// или не ответила после всех повторов.
+	FallbackModels []string
+}
+
+func DefaultRetryOptions() RetryOptions {
+	return RetryOptions{
+		MaxRetries: 3,
+		BaseDelay:  time.Second,
+		MaxDelay:   30 * time.Second,
+		Budget:     2 * time.Minute,
+	}
+}
+
+// ModelRetry описывает повтор запроса: Model — модель, с которой запрос не
+// удался, Next — модель следующей попытки.
+type ModelRetry struct {
+	Model   string
+	Next    string
+	Attempt int
+	Delay   time.Duration
+	Err     error
+}
+
+type retryHookKey struct{}
+
+// withRetryHook передает RetryProvider обработчик повторов для запросов
+// с контекстом ctx: так агент показывает повторы в событиях своей задачи.
+func withRetryHook(ctx context.Context, hook func(ModelRetry)) context.Context {
+	return context.WithValue(ctx, retryHookKey{}, hook)
+}
+
+// RetryProvider повторяет запросы к модели после временных ошибок с
+// экспоненциальной паузой и переходит на запасные модели.
+type RetryProvider struct {
+	provider LLMProvider
+	opts     RetryOptions
+}
+
+func NewRetryProvider(provider LLMProvider, opts RetryOptions) *RetryProvider {
+	defaults := DefaultRetryOptions()
+	if opts.BaseDelay <= 0 {
+		opts.BaseDelay = defaults.BaseDelay
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = defaults.MaxDelay
+	}
+	return &RetryProvider{provider: provider, opts: opts}
+}
+
+// Chat выполняет запрос к req.Model, а если модель недоступна или не
+// ответила после повторов — к запасным моделям. ChatResponse.Model
+// содержит модель, которая ответила. Ошибки, которые повтор не исправит
+// (авторизация, некорректный запрос), возвращаются сразу.
+func (p *RetryProvider) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	hook, _ := ctx.Value(retryHookKey{}).(func(ModelRetry))
+	models := append([]string{req.Model}, p.opts.FallbackModels...)
+	budget := p.opts.Budget
+
+	var lastErr error
+	for i, model := range models {
+		req.Model = model
+		for attempt := 0; ; attempt++ {
+			resp, err := p.provider.Chat(ctx, req)
+			if err == nil {
+				resp.Model = model
+				return resp, nil
+			}
+			lastErr = err
+
+			var providerErr *ProviderError
+			if ctx.Err() != nil || !errors.As(err, &providerErr) {
+				return ChatResponse{}, err
+			}
+			if !providerErr.Temporary() && providerErr.Kind != ErrorModelUnavailable {
+				return ChatResponse{}, err
+			}
+
+			delay := p.backoff(attempt, providerErr.RetryAfter)
+			if !providerErr.Temporary() || attempt >= p.opts.MaxRetries || delay > budget {
+				// К этой модели больше не обращаемся, следующая пробуется
+				// сразу.
+				if hook != nil && i+1 < len(models) {
+					hook(ModelRetry{Model: model, Next: models[i+1], Attempt: attempt + 1, Err: err})
+				}
+				break
+			}
+
+			if hook != nil {
+				hook(ModelRetry{Model: model, Next: model, Attempt: attempt + 1, Delay: delay, Err: err})
+			}
+			budget -= delay
+			if err := timeutil.Sleep(ctx, delay); err != nil {
+				return ChatResponse{}, err
+			}
+		}
+	}
+	return ChatResponse{}, lastErr
+}
+
+// backoff возвращает паузу перед повтором номер attempt+1: Retry-After
+// сервера, если он задан, иначе BaseDelay·2^attempt, ограниченную MaxDelay,
+// со случайным разбросом в нижнюю половину.
+func (p *RetryProvider) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+	delay := p.opts.MaxDelay
+	if attempt < 30 {
+		if d := p.opts.BaseDelay << attempt; d > 0 && d < delay {
+			delay = d
+		}
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
@@ -0,0 +1,87 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrCostLimit — задача остановлена, потому что ее стоимость превысила
+// Options.MaxTaskCost.
+var ErrCostLimit = errors.New("превышен лимит стоимости задачи")
+
+// Price — цена модели в долларах за миллион токенов запроса и ответа.
+type Price struct {
+	Prompt     float64
+	Completion float64
+}
+
+// DefaultPrices возвращает цены моделей OpenAI на момент написания. Цены
+// меняются, поэтому их можно переопределить в Options.Prices.
+func DefaultPrices() map[string]Price {
+	return map[string]Price{
+		"gpt-4-turbo-preview": {Prompt: 10, Completion: 30},
+		"gpt-4-turbo":         {Prompt: 10, Completion: 30},
+		"gpt-4":               {Prompt: 30, Completion: 60},
+		"gpt-4o":              {Prompt: 2.5, Completion: 10},
+		"gpt-4o-mini":         {Prompt: 0.15, Completion: 0.6},
+		"gpt-3.5-turbo":       {Prompt: 0.5, Completion: 1.5},
+	}
+}
+
+// Cost возвращает стоимость usage в долларах.
+func (p Price) Cost(usage Usage) float64 {
+	return (float64(usage.PromptTokens)*p.Prompt + float64(usage.CompletionTokens)*p.Completion) / 1e6
+}
+
+// Add возвращает сумму расхода токенов.
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		TotalTokens:      u.TotalTokens + other.TotalTokens,
+	}
+}
+
+// recordUsage добавляет расход одного запроса к модели к итогам задачи и
+// сессии агента и возвращает его стоимость. Для модели без цены в
+// Options.Prices стоимость считается нулевой, а задача помечается Unpriced.
+func (a *AIAgent) recordUsage(res *TaskResult, model string, usage Usage) float64 {
+	price, ok := a.opts.Prices[model]
+	if !ok {
+		res.Unpriced = true
+	}
+	cost := price.Cost(usage)
+	res.Usage = res.Usage.Add(usage)
+	res.Cost += cost
+
+	a.session.Usage = a.session.Usage.Add(usage)
+	a.session.Cost += cost
+	return cost
+}
+
+// checkCost возвращает ErrCostLimit, если стоимость задачи превысила лимит.
+func (a *AIAgent) checkCost(res *TaskResult) error {
+	if a.opts.MaxTaskCost <= 0 || res.Cost <= a.opts.MaxTaskCost {
+		return nil
+	}
+	return fmt.Errorf("%w: $%.4f при лимите $%.4f", ErrCostLimit, res.Cost, a.opts.MaxTaskCost)
+}
+
+// SessionUsage — расход агента за все задачи с момента создания.
+type SessionUsage struct {
+	Tasks int
+	Usage Usage
+	Cost  float64
+}
+
+func (a *AIAgent) SessionUsage() SessionUsage {
+	return a.session
+}
+
+// FormatCost записывает стоимость в долларах для вывода пользователю.
+func FormatCost(cost float64, unpriced bool) string {
+	if unpriced {
+		return fmt.Sprintf("$%.4f (цена модели неизвестна, стоимость занижена)", cost)
+	}
+	return fmt.Sprintf("$%.4f", cost)
+}
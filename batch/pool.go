@@ -0,0 +1,104 @@
+package batch
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"ai-browser-agent/agent"
+	"ai-browser-agent/browser"
+)
+
+// Pool выполняет задачи параллельно в одном Chrome. Каждая задача получает
+// свой агент и свой инкогнито контекст браузера, поэтому задачи не видят
+// куки, вкладки и историю друг друга.
+type Pool struct {
+	browser  *browser.BrowserManager
+	provider agent.LLMProvider
+	opts     agent.Options
+	workers  int
+}
+
+// NewPool создает пул из workers исполнителей. Файлы задач сохраняются в
+// подкаталоги opts.RunDir с именами задач.
+func NewPool(browserManager *browser.BrowserManager, provider agent.LLMProvider, opts agent.Options, workers int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Pool{
+		browser:  browserManager,
+		provider: provider,
+		opts:     opts,
+		workers:  workers,
+	}
+}
+
+type indexedResult struct {
+	index  int
+	result Result
+}
+
+// Run выполняет задачи и возвращает результаты в порядке задач. onResult,
+// если задан, вызывается после каждой задачи из горутины исполнителя,
+// поэтому должен быть безопасен для параллельных вызовов.
+func (p *Pool) Run(ctx context.Context, tasks []Task, onResult func(Result)) []Result {
+	queue := make(chan int)
+	go func() {
+		defer close(queue)
+		for i := range tasks {
+			queue <- i
+		}
+	}()
+
+	// Каждый исполнитель собирает свои результаты сам, общий срез
+	// заполняется после завершения всех исполнителей.
+	collected := make([][]indexedResult, p.workers)
+	var wg sync.WaitGroup
+	for w := 0; w < p.workers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := range queue {
+				result := p.runTask(ctx, tasks[i])
+				result.Worker = worker + 1
+				if onResult != nil {
+					onResult(result)
+				}
+				collected[worker] = append(collected[worker], indexedResult{index: i, result: result})
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	results := make([]Result, len(tasks))
+	for _, workerResults := range collected {
+		for _, r := range workerResults {
+			results[r.index] = r.result
+		}
+	}
+	return results
+}
+
+func (p *Pool) runTask(ctx context.Context, task Task) Result {
+	started := time.Now()
+	if err := ctx.Err(); err != nil {
+		return Failed(task, started, fmt.Errorf("задача отменена: %w", err))
+	}
+
+	incognito, err := p.browser.Incognito()
+	if err != nil {
+		return Failed(task, started, err)
+	}
+	defer incognito.Close()
+
+	opts := p.opts
+	opts.RunDir = filepath.Join(p.opts.RunDir, task.ID)
+	aiAgent, err := agent.NewAIAgent(p.provider, incognito, opts)
+	if err != nil {
+		return Failed(task, started, err)
+	}
+
+	return Run(ctx, aiAgent, task)
+}
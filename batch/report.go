@@ -0,0 +1,131 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"ai-browser-agent/agent"
+)
+
+type Status string
+
+const (
+	StatusSuccess   Status = "success"
+	StatusFailed    Status = "failed"
+	StatusTimeout   Status = "timeout"
+	StatusCancelled Status = "cancelled"
+)
+
+// Result — строка отчета о выполнении задачи.
+type Result struct {
+	ID         string    `json:"id"`
+	Title      string    `json:"title,omitempty"`
+	Task       string    `json:"task"`
+	Status     Status    `json:"status"`
+	Result     string    `json:"result,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Iterations int       `json:"iterations"`
+	DurationMS int64     `json:"duration_ms"`
+	StartedAt  time.Time `json:"started_at"`
+	// Usage и CostUSD — расход токенов и стоимость запросов к модели.
+	Usage   agent.Usage `json:"usage"`
+	CostUSD float64     `json:"cost_usd"`
+	// Unpriced — цена модели неизвестна, CostUSD занижена.
+	Unpriced bool `json:"unpriced,omitempty"`
+	// Worker — номер исполнителя пула, выполнившего задачу.
+	Worker int `json:"worker,omitempty"`
+}
+
+// Run выполняет задачу агентом и превращает итог в строку отчета.
+func Run(ctx context.Context, a *agent.AIAgent, task Task) Result {
+	started := time.Now()
+	res, err := a.ExecuteTaskWithOptions(ctx, task.Task, agent.TaskOptions{
+		SystemPrompt: task.SystemPrompt,
+		Language:     task.Language,
+	})
+
+	result := newResult(task, started, err)
+	result.Result = res.Result
+	result.Iterations = res.Iterations
+	result.Usage = res.Usage
+	result.CostUSD = res.Cost
+	result.Unpriced = res.Unpriced
+	return result
+}
+
+// Failed возвращает строку отчета для задачи, которая не смогла начаться,
+// например из-за ошибки запуска браузера.
+func Failed(task Task, started time.Time, err error) Result {
+	return newResult(task, started, err)
+}
+
+func newResult(task Task, started time.Time, err error) Result {
+	result := Result{
+		ID:         task.ID,
+		Title:      task.Title,
+		Task:       task.Task,
+		Status:     StatusOf(err),
+		DurationMS: time.Since(started).Milliseconds(),
+		StartedAt:  started,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// StatusOf определяет итог задачи по ошибке ExecuteTask: истекший срок
+// означает timeout, отмена — cancelled, остальные ошибки — failed.
+func StatusOf(err error) Status {
+	switch {
+	case err == nil:
+		return StatusSuccess
+	case errors.Is(err, context.DeadlineExceeded):
+		return StatusTimeout
+	case errors.Is(err, context.Canceled):
+		return StatusCancelled
+	default:
+		return StatusFailed
+	}
+}
+
+// ReportWriter дописывает результаты в JSONL файл по мере выполнения задач,
+// чтобы отчет не терялся при аварийном завершении.
+type ReportWriter struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+func CreateReport(path string) (*ReportWriter, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("не удалось создать каталог %s: %w", dir, err)
+		}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать отчет %s: %w", path, err)
+	}
+	return &ReportWriter{file: file, enc: json.NewEncoder(file)}, nil
+}
+
+func (w *ReportWriter) Write(result Result) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.enc.Encode(result); err != nil {
+		return fmt.Errorf("не удалось записать отчет: %w", err)
+	}
+	return nil
+}
+
+func (w *ReportWriter) Close() error {
+	return w.file.Close()
+}
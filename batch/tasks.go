@@ -0,0 +1,112 @@
+package batch
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Task — задача из файла задач.
+type Task struct {
+	ID    string
+	Title string
+	Task  string
+	// SystemPrompt и Language переопределяют промпт агента для этой задачи.
+	SystemPrompt string
+	Language     string
+}
+
+// taskLine — строка JSONL файла. Поддерживается и формат requests.jsonl
+// (request_id, title, body), и короткая запись с полями id и task.
+type taskLine struct {
+	ID           string `json:"id"`
+	RequestID    string `json:"request_id"`
+	Title        string `json:"title"`
+	Body         string `json:"body"`
+	Task         string `json:"task"`
+	SystemPrompt string `json:"system_prompt"`
+	Language     string `json:"language"`
+}
+
+var unsafeIDChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// LoadTasks читает файл задач. Каждая строка — либо текст задачи, либо JSON
+// объект. Пустые строки и строки, начинающиеся с #, пропускаются.
+func LoadTasks(path string) ([]Task, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть файл задач: %w", err)
+	}
+	defer file.Close()
+
+	var tasks []Task
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		task, err := parseTask(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		if task.ID == "" {
+			task.ID = fmt.Sprintf("task-%03d", len(tasks)+1)
+		}
+		task.ID = unsafeIDChars.ReplaceAllString(task.ID, "_")
+		if seen[task.ID] {
+			return nil, fmt.Errorf("%s:%d: задача %s встречается дважды", path, lineNo, task.ID)
+		}
+		seen[task.ID] = true
+
+		tasks = append(tasks, task)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("не удалось прочитать файл задач: %w", err)
+	}
+	if len(tasks) == 0 {
+		return nil, fmt.Errorf("в файле %s нет задач", path)
+	}
+	return tasks, nil
+}
+
+func parseTask(line string) (Task, error) {
+	if !strings.HasPrefix(line, "{") {
+		return Task{Task: line}, nil
+	}
+
+	var parsed taskLine
+	if err := json.Unmarshal([]byte(line), &parsed); err != nil {
+		return Task{}, fmt.Errorf("некорректный JSON: %w", err)
+	}
+
+	task := Task{
+		ID:           parsed.ID,
+		Title:        parsed.Title,
+		SystemPrompt: parsed.SystemPrompt,
+		Language:     parsed.Language,
+	}
+	if task.ID == "" {
+		task.ID = parsed.RequestID
+	}
+
+	switch {
+	case parsed.Task != "":
+		task.Task = parsed.Task
+	case parsed.Title != "" && parsed.Body != "":
+		task.Task = parsed.Title + "\n\n" + parsed.Body
+	case parsed.Body != "":
+		task.Task = parsed.Body
+	case parsed.Title != "":
+		task.Task = parsed.Title
+	default:
+		return Task{}, fmt.Errorf("не задан текст задачи (task, body или title)")
+	}
+	return task, nil
+}
@@ -4,102 +4,222 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
+	"ai-browser-agent/internal/timeutil"
+
 	"github.com/go-rod/rod"
 	"github.com/go-rod/rod/lib/launcher"
+	"github.com/go-rod/rod/lib/proto"
 )
 
 type BrowserManager struct {
 	browser *rod.Browser
-	page    *rod.Page
-	ctx     context.Context
+	opts    BrowserOptions
+	// launcher равен nil, если менеджер подключен к уже запущенному Chrome
+	// или работает в инкогнито контексте другого менеджера.
+	launcher *launcher.Launcher
+	// incognito означает, что менеджеру принадлежит только его контекст
+	// браузера; stopEvents останавливает обработку событий этого контекста.
+	incognito  bool
+	stopEvents context.CancelFunc
+
+	// mu защищает вкладки, таблицы ссылок и скрипты инициализации:
+	// новые вкладки добавляются из обработчика событий браузера.
+	mu          sync.Mutex
+	contextID   proto.BrowserBrowserContextID
+	tabs        []*tab
+	active      *tab
+	nextTabID   int
+	openedTabs  []*tab
+	refs        map[proto.TargetTargetID]*refTable
+	initScripts []string
 }
 
-func NewBrowserManager() (*BrowserManager, error) {
-	launcher := launcher.New().
-		Headless(true).
-		NoSandbox(true)
+func NewBrowserManager(opts BrowserOptions) (*BrowserManager, error) {
+	opts = opts.withDefaults()
+	bm := &BrowserManager{
+		opts:      opts,
+		nextTabID: 1,
+		refs:      map[proto.TargetTargetID]*refTable{},
+	}
 
-	url, err := launcher.Launch()
-	if err != nil {
-		return nil, fmt.Errorf("не удалось запустить браузер: %w", err)
+	url := opts.RemoteURL
+	if url != "" {
+		resolved, err := resolveRemoteURL(url)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось подключиться к Chrome по адресу %s: %w", url, err)
+		}
+		url = resolved
+	} else {
+		l, err := newLauncher(opts)
+		if err != nil {
+			return nil, err
+		}
+
+		url, err = l.Launch()
+		if err != nil {
+			return nil, fmt.Errorf("не удалось запустить браузер: %w", err)
+		}
+		bm.launcher = l
 	}
 
-	browser := rod.New().ControlURL(url).MustConnect()
-	if err != nil {
+	bm.browser = rod.New().ControlURL(url)
+	if opts.UserAgent != "" || opts.WindowWidth > 0 || opts.RemoteURL != "" {
+		bm.browser = bm.browser.NoDefaultDevice()
+	}
+	if err := bm.browser.Connect(); err != nil {
+		if bm.launcher != nil {
+			bm.launcher.Kill()
+		}
 		return nil, fmt.Errorf("не удалось подключиться к браузеру: %w", err)
 	}
 
-	page := browser.MustPage()
+	if err := bm.openInitialTab(); err != nil {
+		bm.Close()
+		return nil, err
+	}
+
+	return bm, nil
+}
+
+// Incognito создает менеджер в новом инкогнито контексте того же Chrome: со
+// своими вкладками, куки и localStorage. Закрытие такого менеджера закрывает
+// только его контекст, браузер продолжает работать.
+func (bm *BrowserManager) Incognito() (*BrowserManager, error) {
+	browser, err := bm.browser.Incognito()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать инкогнито контекст: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	child := &BrowserManager{
+		browser:    browser.Context(ctx),
+		opts:       bm.opts,
+		incognito:  true,
+		stopEvents: cancel,
+		nextTabID:  1,
+		refs:       map[proto.TargetTargetID]*refTable{},
+	}
+	if err := child.openInitialTab(); err != nil {
+		child.Close()
+		return nil, err
+	}
+	return child, nil
+}
+
+// openInitialTab открывает первую вкладку, начинает следить за вкладками и
+// загружает сохраненное состояние из BrowserOptions.StorageState.
+func (bm *BrowserManager) openInitialTab() error {
+	page, err := bm.browser.Page(proto.TargetCreateTarget{})
+	if err != nil {
+		return fmt.Errorf("не удалось открыть страницу: %w", err)
+	}
+	if err := bm.preparePage(page); err != nil {
+		return err
+	}
+	if err := bm.trackTabs(page); err != nil {
+		return err
+	}
 
-	return &BrowserManager{
-		browser: browser,
-		page:    page,
-		ctx:     context.Background(),
-	}, nil
+	if bm.opts.StorageState != "" {
+		if err := bm.LoadStorageState(context.Background(), bm.opts.StorageState); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (bm *BrowserManager) Navigate(url string) error {
+// NormalizeURL добавляет https:// к адресу без схемы: модели часто
+// передают адрес в виде example.com.
+func NormalizeURL(url string) string {
 	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
-		url = "https://" + url
+		return "https://" + url
 	}
+	return url
+}
+
+func (bm *BrowserManager) Navigate(ctx context.Context, url string) error {
+	url = NormalizeURL(url)
 
-	err := bm.page.Timeout(30 * time.Second).Navigate(url)
+	page := bm.activePage().Context(ctx)
+
+	err := page.Timeout(bm.opts.NavigationTimeout).Navigate(url)
 	if err != nil {
 		return fmt.Errorf("не удалось загрузить страницу %s: %w", url, err)
 	}
 
-	err = bm.page.WaitLoad()
+	err = page.WaitLoad()
 	if err != nil {
 		return fmt.Errorf("не удалось дождаться загрузки страницы %s: %w", url, err)
 	}
 
-	time.Sleep(2 * time.Second)
-	return nil
+	return timeutil.Sleep(ctx, bm.opts.NavigationDelay)
 }
 
-func (bm *BrowserManager) GetPageContent() (string, error) {
-	html, err := bm.page.HTML()
+func (bm *BrowserManager) GetPageContent(ctx context.Context) (string, error) {
+	html, err := bm.activePage().Context(ctx).HTML()
 	if err != nil {
 		return "", fmt.Errorf("не удалось получить HTML: %w", err)
 	}
 	return html, nil
 }
 
-func (bm *BrowserManager) GetPageText() (string, error) {
-	text, err := bm.page.MustElement("body").Text()
+func (bm *BrowserManager) GetPageText(ctx context.Context) (string, error) {
+	body, err := bm.activePage().Context(ctx).Element("body")
+	if err != nil {
+		return "", fmt.Errorf("не удалось найти body страницы: %w", err)
+	}
+
+	text, err := body.Text()
 	if err != nil {
 		return "", fmt.Errorf("не удалось получить текст страницы: %w", err)
 	}
 	return text, nil
 }
 
-func (bm *BrowserManager) GetPageURL() string {
-	return bm.page.MustInfo().URL
+func (bm *BrowserManager) GetPageURL(ctx context.Context) (string, error) {
+	info, err := bm.activePage().Context(ctx).Info()
+	if err != nil {
+		return "", fmt.Errorf("не удалось получить URL страницы: %w", err)
+	}
+	return info.URL, nil
 }
 
-func (bm *BrowserManager) GetPageTitle() string {
-	return bm.page.MustInfo().Title
+func (bm *BrowserManager) GetPageTitle(ctx context.Context) (string, error) {
+	info, err := bm.activePage().Context(ctx).Info()
+	if err != nil {
+		return "", fmt.Errorf("не удалось получить заголовок страницы: %w", err)
+	}
+	return info.Title, nil
 }
 
-func (bm *BrowserManager) ClickElement(selector string) error {
-	element, err := bm.page.Timeout(10 * time.Second).Element(selector)
+func (bm *BrowserManager) ClickElement(ctx context.Context, loc Locator) error {
+	element, err := bm.findElement(ctx, loc, bm.opts.ElementTimeout)
 	if err != nil {
-		return fmt.Errorf("не удалось найти элемент с селектором %s: %w", selector, err)
+		return fmt.Errorf("не удалось найти элемент %s: %w", loc, err)
 	}
 
-	element.MustClick()
-	time.Sleep(1 * time.Second)
-	return nil
+	err = element.Click(proto.InputMouseButtonLeft, 1)
+	if err != nil {
+		return fmt.Errorf("не удалось кликнуть на элемент %s: %w", loc, err)
+	}
+
+	return timeutil.Sleep(ctx, bm.opts.ClickDelay)
 }
 
-func (bm *BrowserManager) FillInput(selector string, text string) error {
-	element, err := bm.page.Timeout(10 * time.Second).Element(selector)
+func (bm *BrowserManager) FillInput(ctx context.Context, loc Locator, text string) error {
+	element, err := bm.findElement(ctx, loc, bm.opts.ElementTimeout)
 	if err != nil {
-		return fmt.Errorf("не удалось найти поле ввода с селектором %s: %w", selector, err)
+		return fmt.Errorf("не удалось найти поле ввода %s: %w", loc, err)
 	}
 
+	// Input дописывает текст к значению поля, поэтому прежнее значение
+	// сначала выделяется и заменяется. У полей без select(), например
+	// contenteditable, выделить текст нельзя, и он дописывается.
+	element.SelectAllText()
+
 	err = element.Input(text)
 	if err != nil {
 		return fmt.Errorf("не удалось ввести текст в поле: %w", err)
@@ -108,145 +228,113 @@ func (bm *BrowserManager) FillInput(selector string, text string) error {
 	return nil
 }
 
-func (bm *BrowserManager) GetElements(selector string) ([]ElementInfo, error) {
-	elements, err := bm.page.Timeout(10 * time.Second).Elements(selector)
+func (bm *BrowserManager) GetElements(ctx context.Context, selector string) ([]ElementInfo, error) {
+	page := bm.activePage().Context(ctx)
+
+	elements, err := page.Timeout(bm.opts.ElementTimeout).Elements(selector)
 	if err != nil {
 		return nil, fmt.Errorf("не удалось найти элементы с селектором %s: %w", selector, err)
 	}
 
-	var result []ElementInfo
-	for i, elem := range elements {
-		text, _ := elem.Text()
-
-		tag := ""
-		tagResult, err := elem.Eval(`() => this.tagName.toLowerCase()`)
-		if err == nil && tagResult != nil {
-			tagStr := tagResult.Value.String()
-			tagStr = strings.Trim(tagStr, `"`)
-			if tagStr != "" {
-				tag = tagStr
-			}
-		}
-
-		href, _ := elem.Attribute("href")
-		id, _ := elem.Attribute("id")
-		class, _ := elem.Attribute("class")
-		visible, _ := elem.Visible()
-
-		uniqueSelector := bm.generateSelector(elem, selector, i)
-
-		result = append(result, ElementInfo{
-			Selector: uniqueSelector,
-			Tag:      tag,
-			Text:     strings.TrimSpace(text),
-			Href:     href,
-			ID:       id,
-			Class:    class,
-			Visible:  visible,
-			Index:    i,
-		})
-	}
-
-	return result, nil
+	return bm.describeElements(page, elements)
 }
 
 type ElementInfo struct {
-	Selector string
-	Tag      string
-	Text     string
-	Href     *string
-	ID       *string
-	Class    *string
-	Visible  bool
-	Index    int
+	Selector   string
+	Tag        string
+	Text       string
+	Href       *string
+	ID         *string
+	Class      *string
+	Visible    bool
+	InViewport bool
+	Box        Box
+	Index      int
+	Ref        int
 }
 
-func (bm *BrowserManager) generateSelector(elem *rod.Element, baseSelector string, index int) string {
-	if id, err := elem.Attribute("id"); err == nil && id != nil && *id != "" {
-		return fmt.Sprintf("#%s", *id)
-	}
-
-	if dataID, err := elem.Attribute("data-id"); err == nil && dataID != nil && *dataID != "" {
-		return fmt.Sprintf("[data-id='%s']", *dataID)
+// WaitForElement ждет появления элемента по селектору; для ref, который
+// уже указывает на конкретный узел, ждет, пока элемент станет видимым.
+// Нулевой timeout означает ElementTimeout из настроек браузера.
+func (bm *BrowserManager) WaitForElement(ctx context.Context, loc Locator, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = bm.opts.ElementTimeout
 	}
-
-	return fmt.Sprintf("%s:nth-child(%d)", baseSelector, index+1)
-}
-
-func (bm *BrowserManager) Screenshot(path string) error {
-	_, err := bm.page.Screenshot(false, nil)
+	element, err := bm.findElement(ctx, loc, timeout)
 	if err != nil {
-		return fmt.Errorf("не удалось сделать скриншот: %w", err)
+		return err
+	}
+	if loc.Ref > 0 {
+		return element.Timeout(timeout).WaitVisible()
 	}
 	return nil
 }
 
-func (bm *BrowserManager) WaitForElement(selector string, timeout time.Duration) error {
-	_, err := bm.page.Timeout(timeout).Element(selector)
-	return err
-}
-
-func (bm *BrowserManager) ExecuteJavaScript(js string) (interface{}, error) {
-	result, err := bm.page.Eval(js)
+func (bm *BrowserManager) ExecuteJavaScript(ctx context.Context, js string) (interface{}, error) {
+	result, err := bm.activePage().Context(ctx).Eval(js)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка выполнения JavaScript: %w", err)
 	}
 	return result.Value, nil
 }
 
-func (bm *BrowserManager) GetVisibleElements() ([]ElementInfo, error) {
-	js := `
-	() => {
-		const elements = [];
-		const selectors = ['a', 'button', 'input', 'textarea', 'select', '[onclick]', '[role="button"]'];
-		
-		selectors.forEach(selector => {
-			document.querySelectorAll(selector).forEach((el, index) => {
-				const rect = el.getBoundingClientRect();
-				if (rect.width > 0 && rect.height > 0 && 
-					window.getComputedStyle(el).visibility !== 'hidden' &&
-					window.getComputedStyle(el).display !== 'none') {
-					
-					let selector = '';
-					if (el.id) {
-						selector = '#' + el.id;
-					} else if (el.className) {
-						selector = '.' + el.className.split(' ')[0];
-					} else {
-						selector = el.tagName.toLowerCase();
-					}
-					
-					elements.push({
-						selector: selector,
-						tag: el.tagName.toLowerCase(),
-						text: el.textContent.trim().substring(0, 100),
-						href: el.href || null,
-						id: el.id || null,
-						class: el.className || null,
-						visible: true,
-						index: index
-					});
-				}
-			});
-		});
-		
-		return elements;
-	}
-	`
-
-	_, err := bm.ExecuteJavaScript(js)
+// GetVisibleElements возвращает видимые интерактивные элементы страницы
+// (ссылки, кнопки, поля ввода и элементы с ARIA ролями) в порядке документа.
+func (bm *BrowserManager) GetVisibleElements(ctx context.Context) ([]ElementInfo, error) {
+	page := bm.activePage().Context(ctx)
+
+	elements, err := page.ElementsByJS(rod.Eval(collectVisibleElementsJS, interactiveSelector, bm.opts.MaxElements))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("не удалось найти интерактивные элементы: %w", err)
 	}
 
-	return []ElementInfo{}, nil
+	return bm.describeElements(page, elements)
 }
 
+// ResolveSelector возвращает уникальный CSS селектор элемента. Для
+// локатора с селектором он возвращается как есть, для ref селектор
+// строится по текущему состоянию страницы, чтобы действие можно было
+// повторить без снимка страницы.
+func (bm *BrowserManager) ResolveSelector(ctx context.Context, loc Locator) (string, error) {
+	if loc.Ref == 0 {
+		return loc.Selector, nil
+	}
+
+	page := bm.activePage().Context(ctx)
+	element, err := bm.elementByRef(page, loc.Ref)
+	if err != nil {
+		return "", err
+	}
+	info, err := bm.describeElements(page, rod.Elements{element})
+	if err != nil {
+		return "", err
+	}
+	return info[0].Selector, nil
+}
+
+// Close закрывает вкладки агента и браузер. Чужой Chrome, к которому
+// менеджер подключился по RemoteURL, остается работать, а у инкогнито
+// менеджера закрывается только его контекст.
 func (bm *BrowserManager) Close() {
-	if bm.page != nil {
-		bm.page.Close()
+	bm.mu.Lock()
+	tabs := bm.tabs
+	bm.tabs = nil
+	bm.mu.Unlock()
+
+	for _, t := range tabs {
+		t.page.Close()
+	}
+	if bm.incognito {
+		proto.TargetDisposeBrowserContext{BrowserContextID: bm.browser.BrowserContextID}.Call(bm.browser)
+		bm.stopEvents()
+		return
 	}
-	if bm.browser != nil {
-		bm.browser.Close()
+	if bm.launcher == nil {
+		return
+	}
+
+	bm.browser.Close()
+	if bm.opts.Profile == "" {
+		bm.launcher.Cleanup()
 	}
 }
@@ -0,0 +1,160 @@
+package browser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-rod/rod"
+)
+
+// interactiveSelector перечисляет все, с чем пользователь может
+// взаимодействовать. Один querySelectorAll по объединенному селектору
+// возвращает каждый элемент ровно один раз и в порядке документа.
+const interactiveSelector = `a[href], button, input:not([type="hidden"]), textarea, select, summary, ` +
+	`[onclick], [contenteditable="true"], [role="button"], [role="link"], [role="checkbox"], ` +
+	`[role="radio"], [role="tab"], [role="menuitem"], [role="option"], [role="switch"], [role="textbox"], [role="combobox"]`
+
+const collectVisibleElementsJS = `(selector, limit) => {
+	const result = [];
+	for (const el of document.querySelectorAll(selector)) {
+		const rect = el.getBoundingClientRect();
+		const style = window.getComputedStyle(el);
+		if (rect.width > 0 && rect.height > 0 &&
+			style.visibility !== 'hidden' &&
+			style.display !== 'none' &&
+			style.opacity !== '0') {
+			result.push(el);
+			if (result.length >= limit) break;
+		}
+	}
+	return result;
+}`
+
+// describeElementsJS собирает данные о переданных элементах одним вызовом,
+// включая уникальный CSS селектор, проверенный через querySelectorAll.
+const describeElementsJS = `(...elements) => {
+	const unique = (selector) => {
+		try { return document.querySelectorAll(selector).length === 1; } catch (e) { return false; }
+	};
+	const uniqueSelector = (el) => {
+		if (el.id && unique('#' + CSS.escape(el.id))) return '#' + CSS.escape(el.id);
+		const tag = el.tagName.toLowerCase();
+		for (const attr of ['data-testid', 'data-test', 'data-qa', 'data-id', 'name', 'aria-label', 'placeholder']) {
+			const value = el.getAttribute(attr);
+			if (value) {
+				const selector = tag + '[' + attr + '=' + JSON.stringify(value) + ']';
+				if (unique(selector)) return selector;
+			}
+		}
+		const parts = [];
+		for (let node = el; node && node.nodeType === 1 && node !== document.documentElement; node = node.parentElement) {
+			if (node !== el && node.id && unique('#' + CSS.escape(node.id))) {
+				parts.unshift('#' + CSS.escape(node.id));
+				break;
+			}
+			let part = node.tagName.toLowerCase();
+			const parent = node.parentElement;
+			if (parent) {
+				const sameTag = Array.from(parent.children).filter((child) => child.tagName === node.tagName);
+				if (sameTag.length > 1) part += ':nth-of-type(' + (sameTag.indexOf(node) + 1) + ')';
+			}
+			parts.unshift(part);
+			if (unique(parts.join(' > '))) break;
+		}
+		return parts.join(' > ');
+	};
+	const label = (el) => {
+		const text = (el.innerText || el.textContent || '').trim().replace(/\s+/g, ' ');
+		return text || el.getAttribute('aria-label') || el.getAttribute('placeholder') ||
+			el.getAttribute('title') || el.value || el.getAttribute('name') || '';
+	};
+	return elements.map((el) => {
+		const rect = el.getBoundingClientRect();
+		const style = window.getComputedStyle(el);
+		return {
+			selector: uniqueSelector(el),
+			tag: el.tagName.toLowerCase(),
+			text: label(el).substring(0, 100),
+			href: el.getAttribute('href'),
+			id: el.id || null,
+			class: (typeof el.className === 'string' && el.className) || null,
+			visible: rect.width > 0 && rect.height > 0 && style.visibility !== 'hidden' && style.display !== 'none',
+			inViewport: rect.bottom > 0 && rect.right > 0 && rect.top < window.innerHeight && rect.left < window.innerWidth,
+			box: { x: rect.x, y: rect.y, width: rect.width, height: rect.height },
+		};
+	});
+}`
+
+type Box struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+type elementData struct {
+	Selector   string  `json:"selector"`
+	Tag        string  `json:"tag"`
+	Text       string  `json:"text"`
+	Href       *string `json:"href"`
+	ID         *string `json:"id"`
+	Class      *string `json:"class"`
+	Visible    bool    `json:"visible"`
+	InViewport bool    `json:"inViewport"`
+	Box        Box     `json:"box"`
+}
+
+// describeElements превращает найденные элементы в ElementInfo и выдает
+// каждому номер ref в таблице ссылок страницы.
+func (bm *BrowserManager) describeElements(page *rod.Page, elements rod.Elements) ([]ElementInfo, error) {
+	if len(elements) == 0 {
+		return []ElementInfo{}, nil
+	}
+
+	refs, err := bm.pageRefs(page)
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]interface{}, len(elements))
+	for i, elem := range elements {
+		args[i] = elem.Object
+	}
+
+	res, err := page.Eval(describeElementsJS, args...)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить данные элементов: %w", err)
+	}
+
+	var data []elementData
+	if err := res.Value.Unmarshal(&data); err != nil {
+		return nil, fmt.Errorf("не удалось разобрать данные элементов: %w", err)
+	}
+	if len(data) != len(elements) {
+		return nil, fmt.Errorf("получены данные о %d элементах вместо %d", len(data), len(elements))
+	}
+
+	result := make([]ElementInfo, len(elements))
+	for i, elem := range elements {
+		ref, err := bm.elementRef(page, refs, elem)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось получить ref элемента %s: %w", data[i].Selector, err)
+		}
+
+		result[i] = ElementInfo{
+			Selector:   data[i].Selector,
+			Tag:        data[i].Tag,
+			Text:       strings.TrimSpace(data[i].Text),
+			Href:       data[i].Href,
+			ID:         data[i].ID,
+			Class:      data[i].Class,
+			Visible:    data[i].Visible,
+			InViewport: data[i].InViewport,
+			Box:        data[i].Box,
+			Index:      i,
+			Ref:        ref,
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,79 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const marksOverlayID = "__agent_marks__"
+
+// marksCleanupTimeout ограничивает снятие разметки после отмены задачи.
+const marksCleanupTimeout = 5 * time.Second
+
+type mark struct {
+	Ref int `json:"ref"`
+	Box Box `json:"box"`
+}
+
+const drawMarksJS = `(id, marks) => {
+	const colors = ['#e6194b', '#3cb44b', '#4363d8', '#f58231', '#911eb4', '#008080', '#9a6324', '#800000'];
+	const overlay = document.createElement('div');
+	overlay.id = id;
+	overlay.style.cssText = 'position:fixed;left:0;top:0;width:100%;height:100%;pointer-events:none;z-index:2147483647;';
+	marks.forEach((mark, i) => {
+		const color = colors[i % colors.length];
+		const box = document.createElement('div');
+		box.style.cssText = 'position:fixed;box-sizing:border-box;border:2px solid ' + color + ';' +
+			'left:' + mark.box.x + 'px;top:' + mark.box.y + 'px;width:' + mark.box.width + 'px;height:' + mark.box.height + 'px;';
+		const label = document.createElement('span');
+		label.textContent = String(mark.ref);
+		label.style.cssText = 'position:absolute;left:-2px;top:-2px;transform:translateY(-100%);padding:0 3px;' +
+			'background:' + color + ';color:#fff;font:bold 12px/14px monospace;white-space:nowrap;';
+		if (mark.box.y < 16) label.style.transform = 'none';
+		box.appendChild(label);
+		overlay.appendChild(box);
+	});
+	document.documentElement.appendChild(overlay);
+}`
+
+const removeMarksJS = `(id) => { const overlay = document.getElementById(id); if (overlay) overlay.remove(); }`
+
+// MarkedScreenshot снимает видимую область страницы, предварительно
+// обведя каждый интерактивный элемент рамкой с его номером ref. Возвращает
+// изображение и список отмеченных элементов, чтобы номера на картинке можно
+// было сопоставить с элементами и передать в click_element или fill_input.
+func (bm *BrowserManager) MarkedScreenshot(ctx context.Context, path string) ([]byte, []ElementInfo, error) {
+	elements, err := bm.GetVisibleElements(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var marked []ElementInfo
+	marks := []mark{}
+	for _, elem := range elements {
+		if !elem.InViewport {
+			continue
+		}
+		marked = append(marked, elem)
+		marks = append(marks, mark{Ref: elem.Ref, Box: elem.Box})
+	}
+
+	page := bm.activePage()
+	if _, err := page.Context(ctx).Eval(drawMarksJS, marksOverlayID, marks); err != nil {
+		return nil, nil, fmt.Errorf("не удалось отрисовать разметку элементов: %w", err)
+	}
+	// Разметка снимается и после отмены ctx, иначе она останется на странице.
+	defer func() {
+		cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), marksCleanupTimeout)
+		defer cancel()
+		page.Context(cleanupCtx).Eval(removeMarksJS, marksOverlayID)
+	}()
+
+	data, err := bm.Screenshot(ctx, ScreenshotOptions{Mode: ScreenshotViewport, Path: path})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, marked, nil
+}
@@ -0,0 +1,186 @@
+package browser
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/launcher"
+	"github.com/go-rod/rod/lib/launcher/flags"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+type BrowserOptions struct {
+	// Profile — имя постоянного профиля Chrome. Куки, localStorage и прочие
+	// данные профиля сохраняются между запусками. Пустое имя — временный профиль.
+	Profile string
+
+	// Headful показывает окно браузера вместо запуска в headless режиме.
+	Headful bool
+	// BinPath — путь к исполняемому файлу Chrome. Пустой путь — найти или
+	// скачать браузер автоматически.
+	BinPath string
+	// Flags — дополнительные флаги запуска Chrome вида "--name=value".
+	Flags []string
+	// WindowWidth и WindowHeight задают размер окна в пикселях.
+	WindowWidth  int
+	WindowHeight int
+	// Proxy — адрес прокси, например "127.0.0.1:8080" или "socks5://host:1080".
+	Proxy string
+
+	// UserAgent, Locale (например "ru-RU") и Timezone (например
+	// "Europe/Moscow") эмулируются для каждой вкладки.
+	UserAgent string
+	Locale    string
+	Timezone  string
+
+	// RemoteURL — адрес DevTools уже запущенного Chrome (ws://host:9222/devtools/browser/...,
+	// http://host:9222 или просто порт). Если задан, браузер не запускается,
+	// а флаги запуска и профиль игнорируются.
+	RemoteURL string
+
+	// StorageState — JSON файл с куки и localStorage, который загружается
+	// сразу после запуска.
+	StorageState string
+
+	// NavigationTimeout ограничивает загрузку страницы, ElementTimeout —
+	// ожидание элементов. NavigationDelay и ClickDelay — паузы после загрузки
+	// страницы и после клика, чтобы успели отработать скрипты сайта.
+	NavigationTimeout time.Duration
+	ElementTimeout    time.Duration
+	NavigationDelay   time.Duration
+	ClickDelay        time.Duration
+	// MaxSnapshotLength ограничивает длину снимка страницы в символах,
+	// MaxElements — число интерактивных элементов, собираемых со страницы.
+	MaxSnapshotLength int
+	MaxElements       int
+}
+
+// DefaultBrowserOptions возвращает настройки по умолчанию: временный
+// headless профиль и проверенные на практике таймауты и паузы.
+func DefaultBrowserOptions() BrowserOptions {
+	return BrowserOptions{
+		NavigationTimeout: 30 * time.Second,
+		ElementTimeout:    10 * time.Second,
+		NavigationDelay:   2 * time.Second,
+		ClickDelay:        1 * time.Second,
+		MaxSnapshotLength: 20000,
+		MaxElements:       200,
+	}
+}
+
+// withDefaults подставляет значения по умолчанию вместо нулевых таймаутов и
+// лимитов. Нулевые паузы допустимы и остаются как есть.
+func (o BrowserOptions) withDefaults() BrowserOptions {
+	defaults := DefaultBrowserOptions()
+	if o.NavigationTimeout <= 0 {
+		o.NavigationTimeout = defaults.NavigationTimeout
+	}
+	if o.ElementTimeout <= 0 {
+		o.ElementTimeout = defaults.ElementTimeout
+	}
+	if o.MaxSnapshotLength <= 0 {
+		o.MaxSnapshotLength = defaults.MaxSnapshotLength
+	}
+	if o.MaxElements <= 0 {
+		o.MaxElements = defaults.MaxElements
+	}
+	return o
+}
+
+func newLauncher(opts BrowserOptions) (*launcher.Launcher, error) {
+	l := launcher.New().
+		Headless(!opts.Headful).
+		NoSandbox(true)
+
+	if opts.BinPath != "" {
+		l = l.Bin(opts.BinPath)
+	}
+	if opts.Profile != "" {
+		dir, err := ProfileDir(opts.Profile)
+		if err != nil {
+			return nil, err
+		}
+		l = l.UserDataDir(dir)
+	}
+	if opts.WindowWidth > 0 && opts.WindowHeight > 0 {
+		l = l.Set("window-size", fmt.Sprintf("%d,%d", opts.WindowWidth, opts.WindowHeight))
+	}
+	if opts.Proxy != "" {
+		l = l.Proxy(opts.Proxy)
+	}
+	if opts.Locale != "" {
+		l = l.Set("lang", opts.Locale)
+	}
+
+	for _, flag := range opts.Flags {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(flag, "-"), "=")
+		if name == "" {
+			return nil, fmt.Errorf("некорректный флаг Chrome: %q", flag)
+		}
+		if hasValue {
+			l = l.Set(flags.Flag(name), value)
+		} else {
+			l = l.Set(flags.Flag(name))
+		}
+	}
+
+	return l, nil
+}
+
+// resolveRemoteURL превращает адрес DevTools в WebSocket URL браузера.
+func resolveRemoteURL(url string) (string, error) {
+	if strings.Contains(url, "/devtools/browser/") {
+		return url, nil
+	}
+	return launcher.ResolveURL(url)
+}
+
+// preparePage настраивает новую вкладку: эмуляцию из BrowserOptions и
+// скрипты инициализации, загруженные ранее (например, localStorage).
+func (bm *BrowserManager) preparePage(page *rod.Page) error {
+	if bm.opts.UserAgent != "" || bm.opts.Locale != "" {
+		userAgent := bm.opts.UserAgent
+		if userAgent == "" {
+			version, err := bm.browser.Version()
+			if err != nil {
+				return fmt.Errorf("не удалось получить версию браузера: %w", err)
+			}
+			userAgent = version.UserAgent
+		}
+
+		err := proto.EmulationSetUserAgentOverride{
+			UserAgent:      userAgent,
+			AcceptLanguage: bm.opts.Locale,
+		}.Call(page)
+		if err != nil {
+			return fmt.Errorf("не удалось установить user agent: %w", err)
+		}
+	}
+
+	if bm.opts.Locale != "" {
+		err := proto.EmulationSetLocaleOverride{Locale: strings.ReplaceAll(bm.opts.Locale, "-", "_")}.Call(page)
+		if err != nil {
+			return fmt.Errorf("не удалось установить локаль %s: %w", bm.opts.Locale, err)
+		}
+	}
+
+	if bm.opts.Timezone != "" {
+		err := proto.EmulationSetTimezoneOverride{TimezoneID: bm.opts.Timezone}.Call(page)
+		if err != nil {
+			return fmt.Errorf("не удалось установить часовой пояс %s: %w", bm.opts.Timezone, err)
+		}
+	}
+
+	bm.mu.Lock()
+	scripts := append([]string(nil), bm.initScripts...)
+	bm.mu.Unlock()
+
+	for _, script := range scripts {
+		if _, err := page.EvalOnNewDocument(script); err != nil {
+			return fmt.Errorf("не удалось добавить скрипт инициализации: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package browser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ProfileDir возвращает каталог данных Chrome для именованного профиля:
+// <каталог настроек пользователя>/ai-browser-agent/profiles/<name>.
+func ProfileDir(name string) (string, error) {
+	if !profileNamePattern.MatchString(name) {
+		return "", fmt.Errorf("некорректное имя профиля %q: допустимы латинские буквы, цифры, точка, дефис и подчеркивание", name)
+	}
+
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("не удалось определить каталог настроек: %w", err)
+	}
+
+	dir := filepath.Join(base, "ai-browser-agent", "profiles", name)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("не удалось создать каталог профиля %s: %w", dir, err)
+	}
+	return dir, nil
+}
@@ -0,0 +1,137 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// Locator указывает на элемент страницы: CSS селектором или номером ref,
+// выданным снимком страницы или списком элементов.
+type Locator struct {
+	Selector string
+	Ref      int
+}
+
+func (l Locator) String() string {
+	if l.Ref > 0 {
+		return fmt.Sprintf("ref=%d", l.Ref)
+	}
+	return l.Selector
+}
+
+// refTable выдает элементам страницы короткие числовые ссылки. Один и тот же
+// DOM узел получает один и тот же номер при повторных снимках страницы.
+// Номера не переиспользуются, поэтому ссылка из старого документа после
+// навигации распознается как устаревшая, а не указывает на чужой элемент.
+type refTable struct {
+	loaderID proto.NetworkLoaderID
+	next     int
+	byNode   map[proto.DOMBackendNodeID]int
+	nodes    map[int]proto.DOMBackendNodeID
+}
+
+func newRefTable() *refTable {
+	return &refTable{
+		next:   1,
+		byNode: map[proto.DOMBackendNodeID]int{},
+		nodes:  map[int]proto.DOMBackendNodeID{},
+	}
+}
+
+func (t *refTable) refFor(node proto.DOMBackendNodeID) int {
+	if ref, ok := t.byNode[node]; ok {
+		return ref
+	}
+
+	ref := t.next
+	t.next++
+	t.byNode[node] = ref
+	t.nodes[ref] = node
+	return ref
+}
+
+// sync сбрасывает ссылки, если в главном фрейме загрузился новый документ.
+func (t *refTable) sync(loaderID proto.NetworkLoaderID) {
+	if t.loaderID == loaderID {
+		return
+	}
+	t.loaderID = loaderID
+	t.byNode = map[proto.DOMBackendNodeID]int{}
+	t.nodes = map[int]proto.DOMBackendNodeID{}
+}
+
+func staleRefError(ref int) error {
+	return fmt.Errorf("ссылка ref=%d устарела: страница изменилась после снимка, получите новый снимок страницы", ref)
+}
+
+// pageRefs возвращает таблицу ссылок текущей страницы, актуальную для
+// загруженного в ней документа.
+func (bm *BrowserManager) pageRefs(page *rod.Page) (*refTable, error) {
+	bm.mu.Lock()
+	table, ok := bm.refs[page.TargetID]
+	if !ok {
+		table = newRefTable()
+		bm.refs[page.TargetID] = table
+	}
+	bm.mu.Unlock()
+
+	tree, err := proto.PageGetFrameTree{}.Call(page)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить состояние страницы: %w", err)
+	}
+	table.sync(tree.FrameTree.Frame.LoaderID)
+
+	return table, nil
+}
+
+func (bm *BrowserManager) elementByRef(page *rod.Page, ref int) (*rod.Element, error) {
+	table, err := bm.pageRefs(page)
+	if err != nil {
+		return nil, err
+	}
+
+	node, ok := table.nodes[ref]
+	if !ok {
+		if ref > 0 && ref < table.next {
+			return nil, staleRefError(ref)
+		}
+		return nil, fmt.Errorf("ссылка ref=%d не найдена, получите снимок страницы", ref)
+	}
+
+	element, err := page.ElementFromNode(&proto.DOMNode{BackendNodeID: node})
+	if err != nil {
+		return nil, staleRefError(ref)
+	}
+	return element, nil
+}
+
+func (bm *BrowserManager) elementRef(page *rod.Page, table *refTable, element *rod.Element) (int, error) {
+	node, err := element.Describe(0, false)
+	if err != nil {
+		return 0, err
+	}
+	return table.refFor(node.BackendNodeID), nil
+}
+
+// findElement находит элемент по локатору. Для селектора ожидает его
+// появления не дольше timeout, ссылка разрешается сразу.
+func (bm *BrowserManager) findElement(ctx context.Context, loc Locator, timeout time.Duration) (*rod.Element, error) {
+	page := bm.activePage().Context(ctx)
+
+	if loc.Ref > 0 {
+		return bm.elementByRef(page, loc.Ref)
+	}
+	if loc.Selector == "" {
+		return nil, fmt.Errorf("не указан ни селектор, ни ref элемента")
+	}
+
+	element, err := page.Timeout(timeout).Element(loc.Selector)
+	if err != nil {
+		return nil, err
+	}
+	return element.CancelTimeout(), nil
+}
@@ -0,0 +1,70 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/go-rod/rod/lib/proto"
+)
+
+type ScreenshotMode string
+
+const (
+	ScreenshotViewport ScreenshotMode = "viewport"
+	ScreenshotFullPage ScreenshotMode = "full_page"
+	ScreenshotElement  ScreenshotMode = "element"
+	// ScreenshotMarks — видимая область с пронумерованными рамками вокруг
+	// интерактивных элементов. Снимается только через MarkedScreenshot:
+	// без списка элементов номера на картинке бесполезны.
+	ScreenshotMarks ScreenshotMode = "marks"
+)
+
+type ScreenshotOptions struct {
+	Mode ScreenshotMode
+	// Target — элемент для режима ScreenshotElement.
+	Target Locator
+	// Path — файл, куда сохранить PNG. Пустой путь — не сохранять.
+	Path string
+}
+
+// Screenshot снимает PNG текущей страницы и при заданном Path сохраняет его
+// на диск, создавая недостающие каталоги.
+func (bm *BrowserManager) Screenshot(ctx context.Context, opts ScreenshotOptions) ([]byte, error) {
+	var (
+		data []byte
+		err  error
+	)
+
+	switch opts.Mode {
+	case ScreenshotViewport, "":
+		data, err = bm.activePage().Context(ctx).Screenshot(false, nil)
+	case ScreenshotFullPage:
+		data, err = bm.activePage().Context(ctx).Screenshot(true, nil)
+	case ScreenshotElement:
+		element, findErr := bm.findElement(ctx, opts.Target, bm.opts.ElementTimeout)
+		if findErr != nil {
+			return nil, fmt.Errorf("не удалось найти элемент %s: %w", opts.Target, findErr)
+		}
+		data, err = element.Screenshot(proto.PageCaptureScreenshotFormatPng, 0)
+	case ScreenshotMarks:
+		return nil, fmt.Errorf("скриншот с разметкой элементов снимается через MarkedScreenshot, который возвращает и легенду")
+	default:
+		return nil, fmt.Errorf("неизвестный режим скриншота: %s", opts.Mode)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось сделать скриншот: %w", err)
+	}
+
+	if opts.Path != "" {
+		if err := os.MkdirAll(filepath.Dir(opts.Path), 0o755); err != nil {
+			return nil, fmt.Errorf("не удалось создать каталог для скриншота: %w", err)
+		}
+		if err := os.WriteFile(opts.Path, data, 0o644); err != nil {
+			return nil, fmt.Errorf("не удалось сохранить скриншот: %w", err)
+		}
+	}
+
+	return data, nil
+}
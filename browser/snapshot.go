@@ -0,0 +1,131 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// Роли, которые сами по себе ничего не сообщают модели: такие узлы
+// пропускаются, а их потомки поднимаются на уровень выше.
+var transparentRoles = map[string]bool{
+	"none":          true,
+	"generic":       true,
+	"presentation":  true,
+	"InlineTextBox": true,
+	"LineBreak":     true,
+}
+
+var snapshotStates = []proto.AccessibilityAXPropertyName{
+	proto.AccessibilityAXPropertyNameFocused,
+	proto.AccessibilityAXPropertyNameDisabled,
+	proto.AccessibilityAXPropertyNameRequired,
+	proto.AccessibilityAXPropertyNameReadonly,
+	"checked",
+	"pressed",
+	"selected",
+	"expanded",
+	proto.AccessibilityAXPropertyNameLevel,
+}
+
+// Snapshot строит компактное текстовое дерево доступности текущей страницы:
+// роль, имя и состояние каждого значимого узла с номером ref, по которому
+// к элементу можно обратиться в других инструментах.
+func (bm *BrowserManager) Snapshot(ctx context.Context) (string, error) {
+	page := bm.activePage().Context(ctx)
+
+	tree, err := proto.AccessibilityGetFullAXTree{}.Call(page)
+	if err != nil {
+		return "", fmt.Errorf("не удалось получить дерево доступности: %w", err)
+	}
+
+	refs, err := bm.pageRefs(page)
+	if err != nil {
+		return "", err
+	}
+
+	nodes := make(map[proto.AccessibilityAXNodeID]*proto.AccessibilityAXNode, len(tree.Nodes))
+	for _, node := range tree.Nodes {
+		nodes[node.NodeID] = node
+	}
+
+	var out strings.Builder
+	for _, node := range tree.Nodes {
+		if _, hasParent := nodes[node.ParentID]; node.ParentID == "" || !hasParent {
+			writeSnapshotNode(&out, refs, nodes, node, 0, "")
+		}
+	}
+
+	snapshot := out.String()
+	if len(snapshot) > bm.opts.MaxSnapshotLength {
+		snapshot = snapshot[:bm.opts.MaxSnapshotLength] + "\n... (снимок обрезан)"
+	}
+	return snapshot, nil
+}
+
+func writeSnapshotNode(out *strings.Builder, refs *refTable, nodes map[proto.AccessibilityAXNodeID]*proto.AccessibilityAXNode, node *proto.AccessibilityAXNode, depth int, parentName string) {
+	role := axString(node.Role)
+	name := strings.TrimSpace(axString(node.Name))
+
+	visible := !node.Ignored && !transparentRoles[role]
+	if role == "StaticText" && (name == "" || name == parentName) {
+		visible = false
+	}
+
+	childDepth := depth
+	if visible {
+		out.WriteString(strings.Repeat("  ", depth))
+		out.WriteString("- ")
+		out.WriteString(role)
+		if name != "" {
+			fmt.Fprintf(out, " %q", name)
+		}
+		if value := strings.TrimSpace(axString(node.Value)); value != "" && value != name {
+			fmt.Fprintf(out, " value=%q", value)
+		}
+		for _, state := range axStates(node) {
+			fmt.Fprintf(out, " [%s]", state)
+		}
+		if node.BackendDOMNodeID != 0 && role != "StaticText" {
+			fmt.Fprintf(out, " [ref=%d]", refs.refFor(node.BackendDOMNodeID))
+		}
+		out.WriteString("\n")
+
+		childDepth = depth + 1
+		parentName = name
+	}
+
+	for _, childID := range node.ChildIDs {
+		if child, ok := nodes[childID]; ok {
+			writeSnapshotNode(out, refs, nodes, child, childDepth, parentName)
+		}
+	}
+}
+
+func axString(value *proto.AccessibilityAXValue) string {
+	if value == nil || value.Value.Nil() {
+		return ""
+	}
+	return fmt.Sprint(value.Value.Val())
+}
+
+func axStates(node *proto.AccessibilityAXNode) []string {
+	var states []string
+	for _, name := range snapshotStates {
+		for _, prop := range node.Properties {
+			if prop.Name != name {
+				continue
+			}
+			switch value := axString(prop.Value); value {
+			case "", "false":
+			case "true":
+				states = append(states, string(name))
+			default:
+				states = append(states, fmt.Sprintf("%s=%s", name, value))
+			}
+		}
+	}
+	return states
+}
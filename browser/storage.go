@@ -0,0 +1,162 @@
+package browser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// StorageState — переносимое состояние авторизации: куки браузера и
+// localStorage открытых сайтов.
+type StorageState struct {
+	Cookies []*proto.NetworkCookie `json:"cookies"`
+	Origins []OriginStorage        `json:"origins"`
+}
+
+type OriginStorage struct {
+	Origin       string        `json:"origin"`
+	LocalStorage []StorageItem `json:"localStorage"`
+}
+
+type StorageItem struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+const readLocalStorageJS = `() => {
+	if (!location.origin.startsWith('http')) return null;
+	const items = [];
+	for (let i = 0; i < localStorage.length; i++) {
+		const name = localStorage.key(i);
+		items.push({ name, value: localStorage.getItem(name) });
+	}
+	return { origin: location.origin, localStorage: items };
+}`
+
+// restoreLocalStorageJS записывает сохраненные значения для origin текущего
+// документа, не перетирая то, что сайт уже успел записать сам.
+const restoreLocalStorageJS = `(() => {
+	const origins = %s;
+	const items = origins[location.origin];
+	if (!items) return;
+	for (const item of items) {
+		if (localStorage.getItem(item.name) === null) localStorage.setItem(item.name, item.value);
+	}
+})()`
+
+// SaveStorageState сохраняет куки и localStorage всех открытых вкладок в
+// JSON файл.
+func (bm *BrowserManager) SaveStorageState(ctx context.Context, path string) error {
+	cookies, err := bm.browser.Context(ctx).GetCookies()
+	if err != nil {
+		return fmt.Errorf("не удалось получить куки: %w", err)
+	}
+
+	state := StorageState{Cookies: cookies, Origins: []OriginStorage{}}
+	seen := map[string]bool{}
+	for _, page := range bm.tabPages() {
+		res, err := page.Context(ctx).Eval(readLocalStorageJS)
+		if err != nil {
+			return fmt.Errorf("не удалось прочитать localStorage: %w", err)
+		}
+		if res.Value.Nil() {
+			continue
+		}
+
+		var origin OriginStorage
+		if err := res.Value.Unmarshal(&origin); err != nil {
+			return fmt.Errorf("не удалось разобрать localStorage: %w", err)
+		}
+		if seen[origin.Origin] {
+			continue
+		}
+		seen[origin.Origin] = true
+		state.Origins = append(state.Origins, origin)
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("не удалось создать каталог %s: %w", dir, err)
+		}
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("не удалось сохранить состояние в %s: %w", path, err)
+	}
+	return nil
+}
+
+// LoadStorageState загружает куки и localStorage из файла, созданного
+// SaveStorageState. localStorage восстанавливается при открытии страниц
+// соответствующих сайтов, в том числе в уже открытых вкладках.
+func (bm *BrowserManager) LoadStorageState(ctx context.Context, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать состояние из %s: %w", path, err)
+	}
+
+	var state StorageState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("не удалось разобрать состояние из %s: %w", path, err)
+	}
+
+	if len(state.Cookies) > 0 {
+		if err := bm.browser.Context(ctx).SetCookies(proto.CookiesToParams(state.Cookies)); err != nil {
+			return fmt.Errorf("не удалось установить куки: %w", err)
+		}
+	}
+
+	origins := map[string][]StorageItem{}
+	for _, origin := range state.Origins {
+		origins[origin.Origin] = append(origins[origin.Origin], origin.LocalStorage...)
+	}
+	if len(origins) == 0 {
+		return nil
+	}
+
+	originsJSON, err := json.Marshal(origins)
+	if err != nil {
+		return err
+	}
+	script := fmt.Sprintf(restoreLocalStorageJS, originsJSON)
+
+	bm.mu.Lock()
+	bm.initScripts = append(bm.initScripts, script)
+	bm.mu.Unlock()
+
+	for _, page := range bm.tabPages() {
+		if err := applyInitScript(page.Context(ctx), script); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyInitScript(page *rod.Page, script string) error {
+	if _, err := page.EvalOnNewDocument(script); err != nil {
+		return fmt.Errorf("не удалось добавить скрипт инициализации: %w", err)
+	}
+	if _, err := page.Eval("() => " + script); err != nil {
+		return fmt.Errorf("не удалось выполнить скрипт инициализации: %w", err)
+	}
+	return nil
+}
+
+func (bm *BrowserManager) tabPages() []*rod.Page {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	pages := make([]*rod.Page, len(bm.tabs))
+	for i, t := range bm.tabs {
+		pages[i] = t.page
+	}
+	return pages
+}
@@ -0,0 +1,282 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+type tab struct {
+	id   int
+	page *rod.Page
+	// prepared — preparePage уже вызван или вызывается для этой вкладки:
+	// вкладку из OpenTab замечает и onTargetCreated, а настраивать ее
+	// нужно один раз.
+	prepared bool
+}
+
+type TabInfo struct {
+	ID     int
+	URL    string
+	Title  string
+	Active bool
+}
+
+// trackTabs делает initial активной вкладкой и подписывается на события
+// браузера, чтобы замечать вкладки и окна, открытые самой страницей
+// (target=_blank, window.open), и закрытые вкладки.
+func (bm *BrowserManager) trackTabs(initial *rod.Page) error {
+	info, err := initial.Info()
+	if err != nil {
+		return fmt.Errorf("не удалось получить информацию о странице: %w", err)
+	}
+
+	bm.mu.Lock()
+	bm.contextID = info.BrowserContextID
+	bm.active = bm.addTabLocked(initial)
+	bm.active.prepared = true
+	bm.mu.Unlock()
+
+	go bm.browser.EachEvent(
+		func(e *proto.TargetTargetCreated) {
+			bm.onTargetCreated(e.TargetInfo)
+		},
+		func(e *proto.TargetTargetDestroyed) {
+			bm.onTargetDestroyed(e.TargetID)
+		},
+	)()
+
+	return nil
+}
+
+func (bm *BrowserManager) onTargetCreated(info *proto.TargetTargetInfo) {
+	if info.Type != proto.TargetTargetInfoTypePage || info.BrowserContextID != bm.contextID {
+		return
+	}
+
+	bm.mu.Lock()
+	tracked := bm.findTabByTargetLocked(info.TargetID) != nil
+	bm.mu.Unlock()
+	if tracked {
+		return
+	}
+
+	page, err := bm.browser.PageFromTarget(info.TargetID)
+	if err != nil {
+		return
+	}
+
+	bm.mu.Lock()
+	if bm.findTabByTargetLocked(info.TargetID) != nil {
+		bm.mu.Unlock()
+		return
+	}
+	t := bm.addTabLocked(page)
+	t.prepared = true
+	bm.openedTabs = append(bm.openedTabs, t)
+
+	// Вкладка, открытая активной страницей, становится активной, как это
+	// происходит в обычном браузере после клика по ссылке.
+	if bm.active != nil && info.OpenerID == bm.active.page.TargetID {
+		bm.active = t
+	}
+	bm.mu.Unlock()
+
+	// Ошибка настройки не повод терять вкладку: она остается доступной
+	// агенту, хоть и без эмуляции.
+	if err := bm.preparePage(page); err != nil {
+		log.Printf("вкладка %d: %v", t.id, err)
+	}
+}
+
+func (bm *BrowserManager) onTargetDestroyed(targetID proto.TargetTargetID) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	if t := bm.findTabByTargetLocked(targetID); t != nil {
+		bm.removeTabLocked(t)
+	}
+}
+
+func (bm *BrowserManager) addTabLocked(page *rod.Page) *tab {
+	t := &tab{id: bm.nextTabID, page: page}
+	bm.nextTabID++
+	bm.tabs = append(bm.tabs, t)
+	return t
+}
+
+// removeTabLocked забывает вкладку. Если закрыта активная вкладка, активной
+// становится последняя из оставшихся; последняя вкладка остается активной,
+// чтобы вызовы возвращали ошибку браузера, а не падали.
+func (bm *BrowserManager) removeTabLocked(t *tab) {
+	for i, existing := range bm.tabs {
+		if existing == t {
+			bm.tabs = append(bm.tabs[:i], bm.tabs[i+1:]...)
+			break
+		}
+	}
+	for i, opened := range bm.openedTabs {
+		if opened == t {
+			bm.openedTabs = append(bm.openedTabs[:i], bm.openedTabs[i+1:]...)
+			break
+		}
+	}
+	delete(bm.refs, t.page.TargetID)
+
+	if bm.active == t && len(bm.tabs) > 0 {
+		bm.active = bm.tabs[len(bm.tabs)-1]
+	}
+}
+
+func (bm *BrowserManager) findTabByTargetLocked(targetID proto.TargetTargetID) *tab {
+	for _, t := range bm.tabs {
+		if t.page.TargetID == targetID {
+			return t
+		}
+	}
+	return nil
+}
+
+func (bm *BrowserManager) findTabLocked(id int) (*tab, error) {
+	for _, t := range bm.tabs {
+		if t.id == id {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("вкладка %d не найдена", id)
+}
+
+func (bm *BrowserManager) activePage() *rod.Page {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	return bm.active.page
+}
+
+func (bm *BrowserManager) ListTabs(ctx context.Context) ([]TabInfo, error) {
+	bm.mu.Lock()
+	tabs := append([]*tab(nil), bm.tabs...)
+	active := bm.active
+	bm.mu.Unlock()
+
+	result := make([]TabInfo, 0, len(tabs))
+	for _, t := range tabs {
+		info, err := t.page.Context(ctx).Info()
+		if err != nil {
+			return nil, fmt.Errorf("не удалось получить информацию о вкладке %d: %w", t.id, err)
+		}
+		result = append(result, TabInfo{
+			ID:     t.id,
+			URL:    info.URL,
+			Title:  info.Title,
+			Active: t == active,
+		})
+	}
+	return result, nil
+}
+
+// OpenTab открывает новую вкладку, делает ее активной и, если url не пуст,
+// переходит на него.
+func (bm *BrowserManager) OpenTab(ctx context.Context, url string) (int, error) {
+	// Страница создается без ctx задачи: rod кэширует ее вместе с контекстом,
+	// а вкладка должна пережить текущую задачу.
+	page, err := bm.browser.Page(proto.TargetCreateTarget{URL: "about:blank"})
+	if err != nil {
+		return 0, fmt.Errorf("не удалось открыть вкладку: %w", err)
+	}
+
+	bm.mu.Lock()
+	t := bm.findTabByTargetLocked(page.TargetID)
+	if t == nil {
+		t = bm.addTabLocked(page)
+	}
+	prepare := !t.prepared
+	t.prepared = true
+	for i, opened := range bm.openedTabs {
+		if opened == t {
+			bm.openedTabs = append(bm.openedTabs[:i], bm.openedTabs[i+1:]...)
+			break
+		}
+	}
+	bm.mu.Unlock()
+
+	if prepare {
+		if err := bm.preparePage(page); err != nil {
+			page.Close()
+			return 0, err
+		}
+	}
+
+	bm.mu.Lock()
+	bm.active = t
+	bm.mu.Unlock()
+
+	if url != "" {
+		if err := bm.Navigate(ctx, url); err != nil {
+			return t.id, err
+		}
+	}
+	return t.id, nil
+}
+
+func (bm *BrowserManager) SwitchTab(ctx context.Context, id int) error {
+	bm.mu.Lock()
+	t, err := bm.findTabLocked(id)
+	bm.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if _, err := t.page.Context(ctx).Activate(); err != nil {
+		return fmt.Errorf("не удалось переключиться на вкладку %d: %w", id, err)
+	}
+
+	bm.mu.Lock()
+	bm.active = t
+	bm.mu.Unlock()
+	return nil
+}
+
+func (bm *BrowserManager) CloseTab(ctx context.Context, id int) error {
+	bm.mu.Lock()
+	t, err := bm.findTabLocked(id)
+	if err == nil && len(bm.tabs) == 1 {
+		err = fmt.Errorf("нельзя закрыть единственную вкладку")
+	}
+	bm.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := t.page.Context(ctx).Close(); err != nil {
+		return fmt.Errorf("не удалось закрыть вкладку %d: %w", id, err)
+	}
+
+	bm.mu.Lock()
+	bm.removeTabLocked(t)
+	bm.mu.Unlock()
+	return nil
+}
+
+// TakeOpenedTabs возвращает вкладки, открытые страницами с прошлого вызова.
+func (bm *BrowserManager) TakeOpenedTabs(ctx context.Context) []TabInfo {
+	bm.mu.Lock()
+	opened := bm.openedTabs
+	bm.openedTabs = nil
+	active := bm.active
+	bm.mu.Unlock()
+
+	result := make([]TabInfo, 0, len(opened))
+	for _, t := range opened {
+		tabInfo := TabInfo{ID: t.id, Active: t == active}
+		if info, err := t.page.Context(ctx).Info(); err == nil {
+			tabInfo.URL = info.URL
+			tabInfo.Title = info.Title
+		}
+		result = append(result, tabInfo)
+	}
+	return result
+}
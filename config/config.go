@@ -0,0 +1,463 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"ai-browser-agent/agent"
+	"ai-browser-agent/browser"
+	"ai-browser-agent/tools"
+	"ai-browser-agent/trace"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config — настройки агента. Значения собираются слоями, каждый следующий
+// перекрывает предыдущий: значения по умолчанию, файл конфигурации (YAML
+// или TOML), переменные окружения и флаги командной строки.
+type Config struct {
+	LLM     LLMConfig     `yaml:"llm" toml:"llm"`
+	Agent   AgentConfig   `yaml:"agent" toml:"agent"`
+	Browser BrowserConfig `yaml:"browser" toml:"browser"`
+	Limits  LimitsConfig  `yaml:"limits" toml:"limits"`
+}
+
+type LLMConfig struct {
+	// Provider — "openai" или "http". Пустое значение выбирает "http", если
+	// задан BaseURL.
+	Provider    string  `yaml:"provider" toml:"provider"`
+	BaseURL     string  `yaml:"base_url" toml:"base_url"`
+	APIKey      string  `yaml:"api_key" toml:"api_key"`
+	Model       string  `yaml:"model" toml:"model"`
+	Temperature float32 `yaml:"temperature" toml:"temperature"`
+	Vision      bool    `yaml:"vision" toml:"vision"`
+	// Prices — цены моделей в долларах за миллион токенов. Значения из
+	// файла дополняют и перекрывают встроенную таблицу.
+	Prices map[string]PriceConfig `yaml:"prices" toml:"prices"`
+
+	// MaxRetries — сколько раз повторять запрос к модели после лимита
+	// запросов, ошибки сервера или сети.
+	MaxRetries int `yaml:"max_retries" toml:"max_retries"`
+	// RetryBudget — сколько всего ждать между повторами одного запроса.
+	RetryBudget time.Duration `yaml:"retry_budget" toml:"retry_budget"`
+	// FallbackModels пробуются по порядку, если основная модель недоступна
+	// или не ответила после всех повторов.
+	FallbackModels []string `yaml:"fallback_models" toml:"fallback_models"`
+}
+
+type PriceConfig struct {
+	Prompt     float64 `yaml:"prompt" toml:"prompt"`
+	Completion float64 `yaml:"completion" toml:"completion"`
+}
+
+type AgentConfig struct {
+	MaxIterations int           `yaml:"max_iterations" toml:"max_iterations"`
+	TaskTimeout   time.Duration `yaml:"task_timeout" toml:"task_timeout"`
+	// RunsDir — каталог, в котором для каждого запуска создается отдельный
+	// подкаталог со скриншотами и другими файлами.
+	RunsDir string `yaml:"runs_dir" toml:"runs_dir"`
+	// Workers — сколько задач выполняется параллельно в пакетном режиме.
+	Workers int `yaml:"workers" toml:"workers"`
+	// Output — формат вывода хода выполнения задач: console, json или none.
+	Output string `yaml:"output" toml:"output"`
+	// ContextBudget — предельный размер диалога в токенах, после которого
+	// старые результаты инструментов сокращаются, а старые ходы
+	// пересказываются моделью.
+	ContextBudget int `yaml:"context_budget" toml:"context_budget"`
+	// MaxTaskCost — предельная стоимость задачи в долларах, 0 — без
+	// ограничения.
+	MaxTaskCost float64 `yaml:"max_task_cost" toml:"max_task_cost"`
+	// Trace — сохранять трассировку каждой задачи в подкаталог trace
+	// каталога запуска: запросы к модели, вызовы инструментов и скриншоты.
+	Trace bool `yaml:"trace" toml:"trace"`
+
+	// PromptFile — файл с шаблоном системного промпта вместо встроенного.
+	PromptFile string `yaml:"prompt_file" toml:"prompt_file"`
+	Language   string `yaml:"language" toml:"language"`
+	// SiteHints — подсказки модели по сайтам: домен и текст подсказки.
+	SiteHints map[string]string `yaml:"site_hints" toml:"site_hints"`
+
+	// systemPrompt — содержимое PromptFile, прочитанное при загрузке.
+	systemPrompt string
+}
+
+type BrowserConfig struct {
+	Profile      string   `yaml:"profile" toml:"profile"`
+	Headful      bool     `yaml:"headful" toml:"headful"`
+	Chrome       string   `yaml:"chrome" toml:"chrome"`
+	Flags        []string `yaml:"flags" toml:"flags"`
+	WindowSize   string   `yaml:"window_size" toml:"window_size"`
+	Proxy        string   `yaml:"proxy" toml:"proxy"`
+	UserAgent    string   `yaml:"user_agent" toml:"user_agent"`
+	Locale       string   `yaml:"locale" toml:"locale"`
+	Timezone     string   `yaml:"timezone" toml:"timezone"`
+	CDPURL       string   `yaml:"cdp_url" toml:"cdp_url"`
+	StorageState string   `yaml:"storage_state" toml:"storage_state"`
+
+	NavigationTimeout time.Duration `yaml:"navigation_timeout" toml:"navigation_timeout"`
+	ElementTimeout    time.Duration `yaml:"element_timeout" toml:"element_timeout"`
+	NavigationDelay   time.Duration `yaml:"navigation_delay" toml:"navigation_delay"`
+	ClickDelay        time.Duration `yaml:"click_delay" toml:"click_delay"`
+	MaxSnapshotChars  int           `yaml:"max_snapshot_chars" toml:"max_snapshot_chars"`
+	MaxElements       int           `yaml:"max_elements" toml:"max_elements"`
+}
+
+type LimitsConfig struct {
+	HTMLChars       int `yaml:"html_chars" toml:"html_chars"`
+	TextChars       int `yaml:"text_chars" toml:"text_chars"`
+	MatchedElements int `yaml:"matched_elements" toml:"matched_elements"`
+	ListedElements  int `yaml:"listed_elements" toml:"listed_elements"`
+}
+
+// Default возвращает конфигурацию со значениями по умолчанию агента,
+// браузера и инструментов.
+func Default() *Config {
+	agentOpts := agent.DefaultOptions()
+	browserOpts := browser.DefaultBrowserOptions()
+	limits := tools.DefaultLimits()
+
+	retry := agent.DefaultRetryOptions()
+
+	prices := map[string]PriceConfig{}
+	for model, price := range agentOpts.Prices {
+		prices[model] = PriceConfig{Prompt: price.Prompt, Completion: price.Completion}
+	}
+
+	return &Config{
+		LLM: LLMConfig{
+			Model:       agentOpts.Model,
+			Temperature: agentOpts.Temperature,
+			Vision:      agentOpts.Vision,
+			Prices:      prices,
+			MaxRetries:  retry.MaxRetries,
+			RetryBudget: retry.Budget,
+		},
+		Agent: AgentConfig{
+			MaxIterations: agentOpts.MaxIterations,
+			TaskTimeout:   agentOpts.TaskTimeout,
+			RunsDir:       "runs",
+			Workers:       1,
+			ContextBudget: agentOpts.ContextBudget,
+			Output:        agent.OutputConsole,
+			Trace:         true,
+			Language:      agentOpts.Language,
+		},
+		Browser: BrowserConfig{
+			NavigationTimeout: browserOpts.NavigationTimeout,
+			ElementTimeout:    browserOpts.ElementTimeout,
+			NavigationDelay:   browserOpts.NavigationDelay,
+			ClickDelay:        browserOpts.ClickDelay,
+			MaxSnapshotChars:  browserOpts.MaxSnapshotLength,
+			MaxElements:       browserOpts.MaxElements,
+		},
+		Limits: LimitsConfig{
+			HTMLChars:       limits.HTMLChars,
+			TextChars:       limits.TextChars,
+			MatchedElements: limits.MatchedElements,
+			ListedElements:  limits.ListedElements,
+		},
+	}
+}
+
+// Load собирает конфигурацию для аргументов командной строки args. Путь к
+// файлу берется из флага -config или переменной AGENT_CONFIG. Флаги
+// конфигурации регистрируются в fs рядом с флагами, которые вызывающий код
+// добавил сам; после Load позиционные аргументы доступны через fs.Args().
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	cfg := Default()
+
+	path := configPath(fs, cfg.settings(), args)
+	if path != "" {
+		if err := cfg.LoadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	fs.String("config", path, "файл конфигурации YAML или TOML (также AGENT_CONFIG)")
+	for _, s := range cfg.settings() {
+		if s.flag == "" {
+			continue
+		}
+		usage := s.usage
+		if s.env != "" {
+			usage += " (также " + s.env + ")"
+		}
+		fs.Var(s.value, s.flag, usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if cfg.Agent.PromptFile != "" {
+		data, err := os.ReadFile(cfg.Agent.PromptFile)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось прочитать шаблон промпта: %w", err)
+		}
+		cfg.Agent.systemPrompt = string(data)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// configPath находит значение флага -config до разбора остальных флагов:
+// файл должен быть прочитан раньше, чтобы флаги могли его перекрыть. Для
+// этого аргументы разбираются отдельным набором с теми же флагами, что и fs,
+// но с пустыми значениями: так значения других флагов не принимаются за
+// конец списка флагов. Ошибки разбора сообщит основной разбор.
+func configPath(fs *flag.FlagSet, settings []setting, args []string) string {
+	pre := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	pre.SetOutput(io.Discard)
+	path := pre.String("config", os.Getenv("AGENT_CONFIG"), "")
+
+	register := func(name string, value flag.Value) {
+		if pre.Lookup(name) != nil {
+			return
+		}
+		if b, ok := value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			pre.Bool(name, false, "")
+		} else {
+			pre.String(name, "", "")
+		}
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		register(f.Name, f.Value)
+	})
+	for _, s := range settings {
+		if s.flag != "" {
+			register(s.flag, s.value)
+		}
+	}
+
+	pre.Parse(args)
+	return *path
+}
+
+// LoadFile читает файл конфигурации поверх текущих значений. Формат
+// определяется по расширению: .yaml, .yml или .toml. Неизвестные ключи
+// считаются ошибкой, чтобы опечатки не проходили молча.
+func (c *Config) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать конфигурацию %s: %w", path, err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("не удалось разобрать конфигурацию %s: %w", path, err)
+		}
+	case ".toml":
+		meta, err := toml.Decode(string(data), c)
+		if err != nil {
+			return fmt.Errorf("не удалось разобрать конфигурацию %s: %w", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("неизвестный ключ %s в конфигурации %s", undecoded[0], path)
+		}
+	default:
+		return fmt.Errorf("неизвестный формат конфигурации %s, ожидается .yaml, .yml или .toml", path)
+	}
+	return nil
+}
+
+func (c *Config) applyEnv() error {
+	for _, s := range c.settings() {
+		if s.env == "" {
+			continue
+		}
+		value, ok := os.LookupEnv(s.env)
+		if !ok {
+			continue
+		}
+		if err := s.value.Set(value); err != nil {
+			return fmt.Errorf("некорректное значение переменной %s: %w", s.env, err)
+		}
+	}
+	return nil
+}
+
+// Validate проверяет конфигурацию целиком и возвращает все найденные ошибки.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	switch c.LLM.Provider {
+	case "", agent.ProviderOpenAI, agent.ProviderHTTP:
+	default:
+		check(false, "llm.provider: неизвестный провайдер %q, ожидается %s или %s", c.LLM.Provider, agent.ProviderOpenAI, agent.ProviderHTTP)
+	}
+	check(c.LLM.Model != "", "llm.model: не задана модель")
+	check(c.LLM.Temperature >= 0 && c.LLM.Temperature <= 2, "llm.temperature: значение %v вне диапазона от 0 до 2", c.LLM.Temperature)
+	for _, model := range sortedKeys(c.LLM.Prices) {
+		price := c.LLM.Prices[model]
+		check(price.Prompt >= 0 && price.Completion >= 0, "llm.prices.%s: цена не может быть отрицательной", model)
+	}
+	check(c.LLM.MaxRetries >= 0, "llm.max_retries: не может быть отрицательным")
+	check(c.LLM.RetryBudget >= 0, "llm.retry_budget: не может быть отрицательным")
+	for i, model := range c.LLM.FallbackModels {
+		check(model != "", "llm.fallback_models[%d]: пустое имя модели", i)
+	}
+
+	check(c.Agent.MaxIterations > 0, "agent.max_iterations: должно быть больше нуля")
+	check(c.Agent.TaskTimeout >= 0, "agent.task_timeout: не может быть отрицательным")
+	check(c.Agent.RunsDir != "", "agent.runs_dir: не задан каталог запусков")
+	check(c.Agent.Workers > 0, "agent.workers: должно быть больше нуля")
+	check(c.Agent.ContextBudget > 0, "agent.context_budget: должно быть больше нуля")
+	check(c.Agent.MaxTaskCost >= 0, "agent.max_task_cost: не может быть отрицательным")
+	if c.Agent.MaxTaskCost > 0 {
+		for _, model := range append([]string{c.LLM.Model}, c.LLM.FallbackModels...) {
+			if _, ok := c.LLM.Prices[model]; !ok && model != "" {
+				check(false, "agent.max_task_cost: для модели %s не задана цена в llm.prices", model)
+			}
+		}
+	}
+	if _, err := agent.NewRenderer(c.Agent.Output, io.Discard); err != nil {
+		check(false, "agent.output: %v", err)
+	}
+	if c.Agent.systemPrompt != "" {
+		_, err := agent.ParseSystemPrompt(c.Agent.systemPrompt)
+		check(err == nil, "agent.prompt_file: %v", err)
+	}
+
+	if c.Browser.WindowSize != "" {
+		_, _, err := c.windowSize()
+		check(err == nil, "browser.window_size: %v", err)
+	}
+	check(c.Browser.CDPURL == "" || c.Browser.Profile == "", "browser.profile: профиль нельзя использовать при подключении по cdp_url")
+	check(c.Browser.NavigationTimeout > 0, "browser.navigation_timeout: должен быть больше нуля")
+	check(c.Browser.ElementTimeout > 0, "browser.element_timeout: должен быть больше нуля")
+	check(c.Browser.NavigationDelay >= 0, "browser.navigation_delay: не может быть отрицательной")
+	check(c.Browser.ClickDelay >= 0, "browser.click_delay: не может быть отрицательной")
+	check(c.Browser.MaxSnapshotChars > 0, "browser.max_snapshot_chars: должно быть больше нуля")
+	check(c.Browser.MaxElements > 0, "browser.max_elements: должно быть больше нуля")
+
+	check(c.Limits.HTMLChars > 0, "limits.html_chars: должно быть больше нуля")
+	check(c.Limits.TextChars > 0, "limits.text_chars: должно быть больше нуля")
+	check(c.Limits.MatchedElements > 0, "limits.matched_elements: должно быть больше нуля")
+	check(c.Limits.ListedElements > 0, "limits.listed_elements: должно быть больше нуля")
+
+	if len(errs) > 0 {
+		return fmt.Errorf("некорректная конфигурация:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func (c *Config) windowSize() (int, int, error) {
+	var width, height int
+	if _, err := fmt.Sscanf(c.Browser.WindowSize, "%dx%d", &width, &height); err != nil || width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("некорректный размер окна %q, ожидается ШИРИНАxВЫСОТА", c.Browser.WindowSize)
+	}
+	return width, height, nil
+}
+
+func (c *Config) ProviderOptions() agent.ProviderOptions {
+	return agent.ProviderOptions{
+		Type:    c.LLM.Provider,
+		BaseURL: c.LLM.BaseURL,
+		APIKey:  c.LLM.APIKey,
+		Retry: agent.RetryOptions{
+			MaxRetries:     c.LLM.MaxRetries,
+			Budget:         c.LLM.RetryBudget,
+			FallbackModels: c.LLM.FallbackModels,
+		},
+	}
+}
+
+// AgentOptions возвращает настройки агента с новым каталогом запуска внутри
+// agent.runs_dir и выводом хода выполнения в stdout в формате agent.output.
+func (c *Config) AgentOptions() agent.Options {
+	var observers []agent.Observer
+	if renderer, _ := agent.NewRenderer(c.Agent.Output, os.Stdout); renderer != nil {
+		observers = append(observers, renderer)
+	}
+	prices := map[string]agent.Price{}
+	for model, price := range c.LLM.Prices {
+		prices[model] = agent.Price{Prompt: price.Prompt, Completion: price.Completion}
+	}
+
+	var factories []agent.ObserverFactory
+	if c.Agent.Trace {
+		factories = append(factories, trace.Factory())
+	}
+
+	return agent.Options{
+		Model:             c.LLM.Model,
+		Temperature:       c.LLM.Temperature,
+		MaxIterations:     c.Agent.MaxIterations,
+		TaskTimeout:       c.Agent.TaskTimeout,
+		RunDir:            filepath.Join(c.Agent.RunsDir, time.Now().Format("20060102-150405")),
+		Vision:            c.LLM.Vision,
+		SystemPrompt:      c.Agent.systemPrompt,
+		Language:          c.Agent.Language,
+		SiteHints:         c.Agent.SiteHints,
+		ContextBudget:     c.Agent.ContextBudget,
+		Prices:            prices,
+		MaxTaskCost:       c.Agent.MaxTaskCost,
+		Observers:         observers,
+		ObserverFactories: factories,
+		Limits: tools.Limits{
+			HTMLChars:       c.Limits.HTMLChars,
+			TextChars:       c.Limits.TextChars,
+			MatchedElements: c.Limits.MatchedElements,
+			ListedElements:  c.Limits.ListedElements,
+		},
+	}
+}
+
+func (c *Config) BrowserOptions() browser.BrowserOptions {
+	opts := browser.BrowserOptions{
+		Profile:           c.Browser.Profile,
+		Headful:           c.Browser.Headful,
+		BinPath:           c.Browser.Chrome,
+		Flags:             c.Browser.Flags,
+		Proxy:             c.Browser.Proxy,
+		UserAgent:         c.Browser.UserAgent,
+		Locale:            c.Browser.Locale,
+		Timezone:          c.Browser.Timezone,
+		RemoteURL:         c.Browser.CDPURL,
+		StorageState:      c.Browser.StorageState,
+		NavigationTimeout: c.Browser.NavigationTimeout,
+		ElementTimeout:    c.Browser.ElementTimeout,
+		NavigationDelay:   c.Browser.NavigationDelay,
+		ClickDelay:        c.Browser.ClickDelay,
+		MaxSnapshotLength: c.Browser.MaxSnapshotChars,
+		MaxElements:       c.Browser.MaxElements,
+	}
+	if c.Browser.WindowSize != "" {
+		opts.WindowWidth, opts.WindowHeight, _ = c.windowSize()
+	}
+	return opts
+}
+
+// sortedKeys возвращает ключи m по алфавиту, чтобы ошибки проверки
+// выводились в одном и том же порядке.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,167 @@
+package config
+
+import (
+	"flag"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// setting связывает поле конфигурации с флагом командной строки и
+// переменной окружения. Пустой flag или env означает, что источник не
+// используется: например, API ключ не передается флагом, чтобы не попадать
+// в список процессов.
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	value flag.Value
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"provider", "AGENT_LLM_PROVIDER", "провайдер модели: openai или http", (*stringValue)(&c.LLM.Provider)},
+		{"base-url", "OPENAI_BASE_URL", "адрес OpenAI-совместимого сервера", (*stringValue)(&c.LLM.BaseURL)},
+		{"", "OPENAI_API_KEY", "", (*stringValue)(&c.LLM.APIKey)},
+		{"model", "OPENAI_MODEL", "модель", (*stringValue)(&c.LLM.Model)},
+		{"temperature", "AGENT_TEMPERATURE", "температура модели", (*float32Value)(&c.LLM.Temperature)},
+		{"vision", "AGENT_VISION", "передавать скриншоты модели", (*boolValue)(&c.LLM.Vision)},
+		{"max-retries", "AGENT_MAX_RETRIES", "сколько раз повторять запрос к модели после временной ошибки", (*intValue)(&c.LLM.MaxRetries)},
+		{"retry-budget", "AGENT_RETRY_BUDGET", "сколько всего ждать между повторами одного запроса к модели", (*durationValue)(&c.LLM.RetryBudget)},
+		{"fallback-model", "", "запасная модель, если основная недоступна (можно повторять)", &listValue{items: &c.LLM.FallbackModels}},
+
+		{"max-iterations", "AGENT_MAX_ITERATIONS", "максимальное количество итераций на задачу", (*intValue)(&c.Agent.MaxIterations)},
+		{"task-timeout", "AGENT_TASK_TIMEOUT", "предельное время задачи, 0 — без ограничения", (*durationValue)(&c.Agent.TaskTimeout)},
+		{"runs-dir", "AGENT_RUNS_DIR", "каталог для файлов запусков", (*stringValue)(&c.Agent.RunsDir)},
+		{"workers", "AGENT_WORKERS", "сколько задач выполнять параллельно", (*intValue)(&c.Agent.Workers)},
+		{"output", "AGENT_OUTPUT", "вывод хода выполнения: console, json или none", (*stringValue)(&c.Agent.Output)},
+		{"context-budget", "AGENT_CONTEXT_BUDGET", "размер диалога в токенах, после которого старые шаги сокращаются", (*intValue)(&c.Agent.ContextBudget)},
+		{"max-task-cost", "AGENT_MAX_TASK_COST", "предельная стоимость задачи в долларах, 0 — без ограничения", (*float64Value)(&c.Agent.MaxTaskCost)},
+		{"trace", "AGENT_TRACE", "сохранять трассировку задач со скриншотами и HTML отчетом", (*boolValue)(&c.Agent.Trace)},
+		{"prompt-file", "AGENT_PROMPT_FILE", "файл с шаблоном системного промпта", (*stringValue)(&c.Agent.PromptFile)},
+		{"language", "AGENT_LANGUAGE", "язык ответов агента", (*stringValue)(&c.Agent.Language)},
+
+		{"profile", "AGENT_PROFILE", "имя постоянного профиля браузера", (*stringValue)(&c.Browser.Profile)},
+		{"headful", "AGENT_HEADFUL", "показывать окно браузера", (*boolValue)(&c.Browser.Headful)},
+		{"chrome", "AGENT_CHROME", "путь к исполняемому файлу Chrome", (*stringValue)(&c.Browser.Chrome)},
+		{"chrome-flag", "", "дополнительный флаг Chrome, например --disable-gpu (можно повторять)", &listValue{items: &c.Browser.Flags}},
+		{"window-size", "AGENT_WINDOW_SIZE", "размер окна браузера, например 1280x800", (*stringValue)(&c.Browser.WindowSize)},
+		{"proxy", "AGENT_PROXY", "адрес прокси сервера", (*stringValue)(&c.Browser.Proxy)},
+		{"user-agent", "AGENT_USER_AGENT", "user agent браузера", (*stringValue)(&c.Browser.UserAgent)},
+		{"locale", "AGENT_LOCALE", "локаль браузера, например ru-RU", (*stringValue)(&c.Browser.Locale)},
+		{"timezone", "AGENT_TIMEZONE", "часовой пояс, например Europe/Moscow", (*stringValue)(&c.Browser.Timezone)},
+		{"cdp-url", "AGENT_CDP_URL", "подключиться к запущенному Chrome по адресу DevTools вместо запуска нового", (*stringValue)(&c.Browser.CDPURL)},
+		{"storage-state", "AGENT_STORAGE_STATE", "JSON файл с куки и localStorage для загрузки при старте", (*stringValue)(&c.Browser.StorageState)},
+		{"navigation-timeout", "AGENT_NAVIGATION_TIMEOUT", "предельное время загрузки страницы", (*durationValue)(&c.Browser.NavigationTimeout)},
+		{"element-timeout", "AGENT_ELEMENT_TIMEOUT", "предельное время ожидания элемента", (*durationValue)(&c.Browser.ElementTimeout)},
+		{"navigation-delay", "AGENT_NAVIGATION_DELAY", "пауза после загрузки страницы", (*durationValue)(&c.Browser.NavigationDelay)},
+		{"click-delay", "AGENT_CLICK_DELAY", "пауза после клика", (*durationValue)(&c.Browser.ClickDelay)},
+		{"max-snapshot-chars", "AGENT_MAX_SNAPSHOT_CHARS", "максимальная длина снимка страницы", (*intValue)(&c.Browser.MaxSnapshotChars)},
+		{"max-elements", "AGENT_MAX_ELEMENTS", "сколько интерактивных элементов собирать со страницы", (*intValue)(&c.Browser.MaxElements)},
+
+		{"html-chars", "AGENT_HTML_CHARS", "сколько символов HTML возвращать модели", (*intValue)(&c.Limits.HTMLChars)},
+		{"text-chars", "AGENT_TEXT_CHARS", "сколько символов текста страницы возвращать модели", (*intValue)(&c.Limits.TextChars)},
+		{"matched-elements", "AGENT_MATCHED_ELEMENTS", "сколько элементов показывает get_elements", (*intValue)(&c.Limits.MatchedElements)},
+		{"listed-elements", "AGENT_LISTED_ELEMENTS", "сколько элементов показывает list_interactive_elements", (*intValue)(&c.Limits.ListedElements)},
+	}
+}
+
+type stringValue string
+
+func (v *stringValue) String() string { return string(*v) }
+
+func (v *stringValue) Set(s string) error {
+	*v = stringValue(s)
+	return nil
+}
+
+type intValue int
+
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+
+func (v *intValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	*v = intValue(n)
+	return nil
+}
+
+type float32Value float32
+
+func (v *float32Value) String() string { return strconv.FormatFloat(float64(*v), 'g', -1, 32) }
+
+func (v *float32Value) Set(s string) error {
+	f, err := strconv.ParseFloat(s, 32)
+	if err != nil {
+		return err
+	}
+	*v = float32Value(f)
+	return nil
+}
+
+type float64Value float64
+
+func (v *float64Value) String() string { return strconv.FormatFloat(float64(*v), 'g', -1, 64) }
+
+func (v *float64Value) Set(s string) error {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	*v = float64Value(f)
+	return nil
+}
+
+type boolValue bool
+
+func (v *boolValue) String() string { return strconv.FormatBool(bool(*v)) }
+
+func (v *boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	*v = boolValue(b)
+	return nil
+}
+
+// IsBoolFlag позволяет писать -headful вместо -headful=true.
+func (v *boolValue) IsBoolFlag() bool { return true }
+
+type durationValue time.Duration
+
+func (v *durationValue) String() string { return time.Duration(*v).String() }
+
+func (v *durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*v = durationValue(d)
+	return nil
+}
+
+// listValue — повторяемый флаг. Первое значение из командной строки
+// заменяет список из файла, следующие добавляются к нему.
+type listValue struct {
+	items    *[]string
+	replaced bool
+}
+
+func (v *listValue) String() string {
+	if v.items == nil {
+		return ""
+	}
+	return strings.Join(*v.items, " ")
+}
+
+func (v *listValue) Set(s string) error {
+	if !v.replaced {
+		*v.items = nil
+		v.replaced = true
+	}
+	*v.items = append(*v.items, s)
+	return nil
+}
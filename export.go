@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"ai-browser-agent/config"
+	"ai-browser-agent/trace"
+)
+
+// exportCommand превращает трассировку успешной задачи в программу на Go,
+// которая повторяет действия агента через rod.
+func exportCommand(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Использование: ai-browser-agent export [флаги] <каталог трассировки>")
+		fs.PrintDefaults()
+	}
+	output := fs.String("o", "", "файл для программы (по умолчанию вывод в stdout)")
+
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка конфигурации: %v\n", err)
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	recorded, err := trace.Load(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка загрузки трассировки: %v\n", err)
+		return 2
+	}
+
+	src, err := trace.Export(recorded, trace.ExportOptions{
+		NavigationTimeout: cfg.Browser.NavigationTimeout,
+		ElementTimeout:    cfg.Browser.ElementTimeout,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка экспорта: %v\n", err)
+		return 1
+	}
+
+	if *output == "" {
+		os.Stdout.Write(src)
+		return 0
+	}
+	if err := os.WriteFile(*output, src, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка записи: %v\n", err)
+		return 2
+	}
+	fmt.Printf(" Программа сохранена: %s\n", *output)
+	return 0
+}
@@ -3,8 +3,13 @@ module ai-browser-agent
 go 1.24.5
 
 require (
-	github.com/go-rod/rod v0.116.2 // indirect
-	github.com/sashabaranov/go-openai v1.41.2 // indirect
+	github.com/BurntSushi/toml v1.6.0
+	github.com/go-rod/rod v0.116.2
+	github.com/sashabaranov/go-openai v1.41.2
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
 	github.com/ysmood/fetchup v0.2.3 // indirect
 	github.com/ysmood/goob v0.4.0 // indirect
 	github.com/ysmood/got v0.40.0 // indirect
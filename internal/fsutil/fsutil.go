@@ -0,0 +1,14 @@
+// Package fsutil содержит общие для пакетов функции работы с файлами.
+package fsutil
+
+import "os"
+
+// WriteFile записывает файл через временный файл рядом с ним и
+// переименование, чтобы при сбое на диске не остался обрезанный файл.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,20 @@
+// Package timeutil содержит общие для пакетов функции ожидания.
+package timeutil
+
+import (
+	"context"
+	"time"
+)
+
+// Sleep ждет d, но прерывается при отмене ctx.
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
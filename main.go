@@ -2,21 +2,55 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
 
 	"ai-browser-agent/agent"
 	"ai-browser-agent/browser"
+	"ai-browser-agent/config"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "run":
+			os.Exit(runCommand(os.Args[2:]))
+		case "serve":
+			os.Exit(serveCommand(os.Args[2:]))
+		case "replay":
+			os.Exit(replayCommand(os.Args[2:]))
+		case "export":
+			os.Exit(exportCommand(os.Args[2:]))
+		}
+	}
+
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		fmt.Printf("Ошибка конфигурации: %v\n", err)
+		os.Exit(2)
+	}
+
 	fmt.Println(" AI Browser Agent запущен!")
 	fmt.Println("Введите задачу для агента (или 'quit' для выхода)")
+	fmt.Println("Команды: 'save-state <файл>' и 'load-state <файл>' сохраняют и загружают куки и localStorage,")
+	fmt.Println("'clear' начинает диалог с агентом заново")
 	fmt.Println()
 
+	// Провайдер создается до запуска браузера, чтобы ошибка в настройках
+	// модели не заставляла ждать Chrome.
+	provider, err := agent.NewProvider(cfg.ProviderOptions())
+	if err != nil {
+		fmt.Printf("Ошибка инициализации AI агента: %v\n", err)
+		return
+	}
+
 	// Инициализация браузера
-	browserManager, err := browser.NewBrowserManager()
+	browserManager, err := browser.NewBrowserManager(cfg.BrowserOptions())
 	if err != nil {
 		fmt.Printf("Ошибка инициализации браузера: %v\n", err)
 		return
@@ -24,12 +58,33 @@ func main() {
 	defer browserManager.Close()
 
 	// Инициализация AI агента
-	aiAgent, err := agent.NewAIAgent(browserManager)
+	aiAgent, err := agent.NewAIAgent(provider, browserManager, cfg.AgentOptions())
 	if err != nil {
 		fmt.Printf("Ошибка инициализации AI агента: %v\n", err)
 		return
 	}
 
+	// Ctrl+C отменяет текущую задачу, но не завершает программу
+	var (
+		mu         sync.Mutex
+		cancelTask context.CancelFunc
+	)
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+	go func() {
+		for range interrupts {
+			mu.Lock()
+			if cancelTask != nil {
+				fmt.Println("\n Отмена текущей задачи...")
+				cancelTask()
+			} else {
+				fmt.Println("\n Для выхода введите 'quit'")
+			}
+			mu.Unlock()
+		}
+	}()
+
 	// Интерактивный цикл
 	scanner := bufio.NewScanner(os.Stdin)
 	for {
@@ -48,10 +103,37 @@ func main() {
 			break
 		}
 
+		if strings.ToLower(task) == "clear" {
+			aiAgent.ClearHistory()
+			fmt.Println(" История диалога очищена")
+			continue
+		}
+
+		if command := strings.Fields(task)[0]; command == "save-state" || command == "load-state" {
+			path := strings.TrimSpace(strings.TrimPrefix(task, command))
+			if path == "" {
+				fmt.Printf(" Укажите файл: %s <файл>\n", command)
+				continue
+			}
+			runStateCommand(browserManager, command, path)
+			continue
+		}
+
 		fmt.Printf("\n Агент выполняет задачу: %s\n\n", task)
 
 		// Выполнение задачи агентом
-		result, err := aiAgent.ExecuteTask(task)
+		ctx, cancel := context.WithCancel(context.Background())
+		mu.Lock()
+		cancelTask = cancel
+		mu.Unlock()
+
+		result, err := aiAgent.ExecuteTask(ctx, task)
+
+		mu.Lock()
+		cancelTask = nil
+		mu.Unlock()
+		cancel()
+
 		if err != nil {
 			fmt.Printf(" Ошибка выполнения задачи: %v\n", err)
 		} else {
@@ -62,4 +144,27 @@ func main() {
 	if err := scanner.Err(); err != nil {
 		fmt.Printf(" Ошибка чтения ввода: %v\n", err)
 	}
+
+	if session := aiAgent.SessionUsage(); session.Tasks > 0 {
+		fmt.Printf(" За сессию: задач %d, токенов %d, стоимость $%.4f\n", session.Tasks, session.Usage.TotalTokens, session.Cost)
+	}
+}
+
+func runStateCommand(browserManager *browser.BrowserManager, command, path string) {
+	ctx := context.Background()
+
+	if command == "save-state" {
+		if err := browserManager.SaveStorageState(ctx, path); err != nil {
+			fmt.Printf(" Ошибка сохранения состояния: %v\n", err)
+			return
+		}
+		fmt.Printf(" Состояние браузера сохранено в %s\n", path)
+		return
+	}
+
+	if err := browserManager.LoadStorageState(ctx, path); err != nil {
+		fmt.Printf(" Ошибка загрузки состояния: %v\n", err)
+		return
+	}
+	fmt.Printf(" Состояние браузера загружено из %s\n", path)
 }
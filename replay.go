@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"ai-browser-agent/browser"
+	"ai-browser-agent/config"
+	"ai-browser-agent/trace"
+)
+
+// replayCommand повторяет вызовы инструментов из трассировки без модели.
+// Возвращает код завершения: 0 — все шаги совпали с записью, 1 — найдено
+// расхождение, 2 — ошибка запуска.
+func replayCommand(args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Использование: ai-browser-agent replay [флаги] <каталог трассировки>")
+		fs.PrintDefaults()
+	}
+	strict := fs.Bool("strict", false, "сравнивать также текст результатов инструментов и адреса целиком")
+
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		fmt.Printf("Ошибка конфигурации: %v\n", err)
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	recorded, err := trace.Load(fs.Arg(0))
+	if err != nil {
+		fmt.Printf("Ошибка загрузки трассировки: %v\n", err)
+		return 2
+	}
+
+	browserManager, err := browser.NewBrowserManager(cfg.BrowserOptions())
+	if err != nil {
+		fmt.Printf("Ошибка инициализации браузера: %v\n", err)
+		return 2
+	}
+	defer browserManager.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	agentOpts := cfg.AgentOptions()
+	fmt.Printf(" Воспроизведение: %s\n\n", recorded.Task)
+	steps, err := trace.Replay(ctx, browserManager, recorded, trace.ReplayOptions{
+		RunDir: filepath.Join(agentOpts.RunDir, "replay"),
+		Limits: agentOpts.Limits,
+		Strict: *strict,
+		OnStep: func(step trace.ReplayStep) {
+			fmt.Printf(" Шаг %d: %s %s\n", step.Index, step.Recorded.Call.Name, step.Recorded.Call.Arguments)
+		},
+	})
+	if err != nil {
+		fmt.Printf("Ошибка: %v\n", err)
+		return 2
+	}
+
+	if len(steps) > 0 {
+		if last := steps[len(steps)-1]; last.Diff != "" {
+			fmt.Printf("\n Расхождение на шаге %d (%s): %s\n", last.Index, last.Recorded.Call.Name, last.Diff)
+			return 1
+		}
+	}
+	fmt.Printf("\n Все шаги совпали с записью: %d\n", len(steps))
+	return 0
+}
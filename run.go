@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"ai-browser-agent/agent"
+	"ai-browser-agent/batch"
+	"ai-browser-agent/browser"
+	"ai-browser-agent/config"
+)
+
+// runCommand выполняет задачи из файла без участия пользователя и пишет
+// JSONL отчет. Возвращает код завершения: 0 — все задачи выполнены,
+// 1 — есть неудачные задачи, 2 — ошибка запуска.
+func runCommand(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Использование: ai-browser-agent run [флаги] <файл задач>")
+		fs.PrintDefaults()
+	}
+	reportPath := fs.String("report", "", "JSONL файл отчета (по умолчанию report.jsonl в каталоге запуска)")
+	freshBrowser := fs.Bool("fresh-browser", false, "запускать отдельный браузер для каждой задачи")
+
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		fmt.Printf("Ошибка конфигурации: %v\n", err)
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	if cfg.Agent.Workers > 1 && *freshBrowser {
+		fmt.Println("Ошибка конфигурации: -fresh-browser нельзя использовать вместе с -workers")
+		return 2
+	}
+
+	tasks, err := batch.LoadTasks(fs.Arg(0))
+	if err != nil {
+		fmt.Printf("Ошибка загрузки задач: %v\n", err)
+		return 2
+	}
+
+	provider, err := agent.NewProvider(cfg.ProviderOptions())
+	if err != nil {
+		fmt.Printf("Ошибка инициализации AI агента: %v\n", err)
+		return 2
+	}
+
+	agentOpts := cfg.AgentOptions()
+	if *reportPath == "" {
+		*reportPath = filepath.Join(agentOpts.RunDir, "report.jsonl")
+	}
+	report, err := batch.CreateReport(*reportPath)
+	if err != nil {
+		fmt.Printf("Ошибка создания отчета: %v\n", err)
+		return 2
+	}
+	defer report.Close()
+
+	// Ctrl+C отменяет текущую задачу, оставшиеся попадают в отчет как отмененные
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var (
+		mu       sync.Mutex
+		writeErr error
+	)
+	onResult := func(result batch.Result) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if result.Status != batch.StatusSuccess {
+			fmt.Printf(" Задача %s: %s: %s\n", result.ID, result.Status, result.Error)
+		}
+		if err := report.Write(result); err != nil && writeErr == nil {
+			writeErr = err
+		}
+	}
+
+	var shared *browser.BrowserManager
+	if !*freshBrowser {
+		shared, err = browser.NewBrowserManager(cfg.BrowserOptions())
+		if err != nil {
+			fmt.Printf("Ошибка инициализации браузера: %v\n", err)
+			return 2
+		}
+		defer shared.Close()
+	}
+
+	var results []batch.Result
+	if cfg.Agent.Workers > 1 {
+		fmt.Printf("\n Выполнение %d задач, параллельно: %d\n", len(tasks), cfg.Agent.Workers)
+		pool := batch.NewPool(shared, provider, agentOpts, cfg.Agent.Workers)
+		results = pool.Run(ctx, tasks, onResult)
+	} else {
+		for i, task := range tasks {
+			fmt.Printf("\n [%d/%d] %s\n", i+1, len(tasks), task.ID)
+
+			result := runBatchTask(ctx, cfg, provider, shared, agentOpts, task)
+			onResult(result)
+			results = append(results, result)
+		}
+	}
+
+	if writeErr != nil {
+		fmt.Printf("Ошибка записи отчета: %v\n", writeErr)
+		return 2
+	}
+
+	var (
+		failed   int
+		usage    agent.Usage
+		cost     float64
+		unpriced bool
+	)
+	for _, result := range results {
+		if result.Status != batch.StatusSuccess {
+			failed++
+		}
+		usage = usage.Add(result.Usage)
+		cost += result.CostUSD
+		unpriced = unpriced || result.Unpriced
+	}
+
+	fmt.Printf("\n Выполнено задач: %d, неудачных: %d. Отчет: %s\n", len(tasks)-failed, failed, *reportPath)
+	fmt.Printf(" Токены: %d, стоимость: %s\n", usage.TotalTokens, agent.FormatCost(cost, unpriced))
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runBatchTask выполняет задачу новым агентом, чтобы история предыдущих
+// задач не попадала в контекст. Браузер общий, если shared не nil, иначе
+// запускается отдельный браузер на время задачи.
+func runBatchTask(ctx context.Context, cfg *config.Config, provider agent.LLMProvider, shared *browser.BrowserManager, agentOpts agent.Options, task batch.Task) batch.Result {
+	started := time.Now()
+	if err := ctx.Err(); err != nil {
+		return batch.Failed(task, started, fmt.Errorf("задача отменена: %w", err))
+	}
+
+	browserManager := shared
+	if browserManager == nil {
+		var err error
+		browserManager, err = browser.NewBrowserManager(cfg.BrowserOptions())
+		if err != nil {
+			return batch.Failed(task, started, err)
+		}
+		defer browserManager.Close()
+	}
+
+	opts := agentOpts
+	opts.RunDir = filepath.Join(agentOpts.RunDir, task.ID)
+	aiAgent, err := agent.NewAIAgent(provider, browserManager, opts)
+	if err != nil {
+		return batch.Failed(task, started, err)
+	}
+
+	return batch.Run(ctx, aiAgent, task)
+}
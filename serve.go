@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"ai-browser-agent/agent"
+	"ai-browser-agent/browser"
+	"ai-browser-agent/config"
+	"ai-browser-agent/server"
+)
+
+// serveCommand запускает HTTP API для отправки задач и слежения за ними.
+func serveCommand(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:8080", "адрес HTTP сервера")
+	storeDir := fs.String("store-dir", "", "каталог для сохранения задач между запусками (по умолчанию задачи хранятся в памяти)")
+
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		fmt.Printf("Ошибка конфигурации: %v\n", err)
+		return 2
+	}
+
+	store, err := server.NewStore(*storeDir)
+	if err != nil {
+		fmt.Printf("Ошибка инициализации хранилища: %v\n", err)
+		return 2
+	}
+
+	provider, err := agent.NewProvider(cfg.ProviderOptions())
+	if err != nil {
+		fmt.Printf("Ошибка инициализации AI агента: %v\n", err)
+		return 2
+	}
+
+	browserManager, err := browser.NewBrowserManager(cfg.BrowserOptions())
+	if err != nil {
+		fmt.Printf("Ошибка инициализации браузера: %v\n", err)
+		return 2
+	}
+	defer browserManager.Close()
+
+	srv := server.New(store, browserManager, provider, cfg.AgentOptions(), cfg.Agent.Workers)
+	defer srv.Close()
+
+	httpServer := &http.Server{Addr: *addr, Handler: srv.Handler()}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf(" HTTP API агента слушает %s\n", *addr)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Printf("Ошибка HTTP сервера: %v\n", err)
+		return 1
+	}
+	fmt.Println(" Сервер остановлен")
+	return 0
+}
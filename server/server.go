@@ -0,0 +1,426 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ai-browser-agent/agent"
+	"ai-browser-agent/batch"
+	"ai-browser-agent/browser"
+)
+
+const queueSize = 1000
+
+// Server принимает задачи по HTTP и выполняет их из очереди. Каждая задача
+// выполняется новым агентом в своем инкогнито контексте общего браузера.
+type Server struct {
+	store    *Store
+	browser  *browser.BrowserManager
+	provider agent.LLMProvider
+	opts     agent.Options
+	queue    chan string
+
+	// ctx отменяется при остановке сервера и прерывает все задачи.
+	ctx      context.Context
+	shutdown context.CancelFunc
+	workers  sync.WaitGroup
+
+	mu       sync.Mutex
+	cancels  map[string]context.CancelFunc
+	watchers map[string]map[chan struct{}]bool
+}
+
+// New создает сервер и запускает workers исполнителей. Файлы задач
+// сохраняются в подкаталоги opts.RunDir с идентификаторами задач.
+func New(store *Store, browserManager *browser.BrowserManager, provider agent.LLMProvider, opts agent.Options, workers int) *Server {
+	if workers < 1 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Server{
+		store:    store,
+		browser:  browserManager,
+		provider: provider,
+		opts:     opts,
+		queue:    make(chan string, queueSize),
+		ctx:      ctx,
+		shutdown: cancel,
+		cancels:  map[string]context.CancelFunc{},
+		watchers: map[string]map[chan struct{}]bool{},
+	}
+
+	for i := 0; i < workers; i++ {
+		s.workers.Add(1)
+		go func() {
+			defer s.workers.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case id := <-s.queue:
+					s.run(id)
+				}
+			}
+		}()
+	}
+	return s
+}
+
+// Close прерывает выполняющиеся задачи и ждет завершения исполнителей.
+// Задачи, оставшиеся в очереди, так и не начнутся.
+func (s *Server) Close() {
+	s.shutdown()
+	s.workers.Wait()
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /tasks", s.handleSubmit)
+	mux.HandleFunc("GET /tasks", s.handleList)
+	mux.HandleFunc("GET /tasks/{id}", s.handleGet)
+	mux.HandleFunc("GET /tasks/{id}/steps", s.handleSteps)
+	mux.HandleFunc("GET /tasks/{id}/result", s.handleResult)
+	mux.HandleFunc("GET /tasks/{id}/events", s.handleEvents)
+	mux.HandleFunc("POST /tasks/{id}/cancel", s.handleCancel)
+	return mux
+}
+
+type submitRequest struct {
+	Task         string `json:"task"`
+	SystemPrompt string `json:"system_prompt"`
+	Language     string `json:"language"`
+}
+
+func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	var req submitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("некорректный JSON: %v", err))
+		return
+	}
+	req.Task = strings.TrimSpace(req.Task)
+	if req.Task == "" {
+		writeError(w, http.StatusBadRequest, "не задан текст задачи")
+		return
+	}
+	if req.SystemPrompt != "" {
+		if _, err := agent.ParseSystemPrompt(req.SystemPrompt); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	record := &TaskRecord{
+		ID:           newID(),
+		Task:         req.Task,
+		SystemPrompt: req.SystemPrompt,
+		Language:     req.Language,
+		Status:       StatusQueued,
+		CreatedAt:    time.Now(),
+	}
+	if err := s.store.Add(record); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	select {
+	case s.queue <- record.ID:
+	default:
+		s.finish(record.ID, agent.TaskResult{}, errors.New("очередь задач переполнена"))
+		writeError(w, http.StatusServiceUnavailable, "очередь задач переполнена")
+		return
+	}
+
+	created, _ := s.store.Get(record.ID)
+	writeJSON(w, http.StatusAccepted, created.Summary())
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.store.List())
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	record, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, record.Summary())
+}
+
+func (s *Server) handleSteps(w http.ResponseWriter, r *http.Request) {
+	record, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, record.Steps)
+}
+
+type resultResponse struct {
+	ID     string `json:"id"`
+	Status Status `json:"status"`
+	Result string `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (s *Server) handleResult(w http.ResponseWriter, r *http.Request) {
+	record, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	if !record.Status.Finished() {
+		writeError(w, http.StatusConflict, fmt.Sprintf("задача %s еще не завершена: %s", record.ID, record.Status))
+		return
+	}
+	writeJSON(w, http.StatusOK, resultResponse{
+		ID:     record.ID,
+		Status: record.Status,
+		Result: record.Result,
+		Error:  record.Error,
+	})
+}
+
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	record, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+
+	// Исполнитель переводит задачу в running под тем же мьютексом, поэтому
+	// задача из очереди не может начаться между проверкой и отменой.
+	s.mu.Lock()
+	cancel, running := s.cancels[record.ID]
+	if running {
+		// Статус выставит исполнитель, когда агент остановится.
+		cancel()
+	} else if record, _ = s.store.Get(record.ID); record.Status == StatusQueued {
+		s.finish(record.ID, agent.TaskResult{}, fmt.Errorf("задача отменена: %w", context.Canceled))
+	}
+	s.mu.Unlock()
+	s.notify(record.ID)
+
+	if !running && record.Status != StatusQueued {
+		writeError(w, http.StatusConflict, fmt.Sprintf("задача %s уже завершена: %s", record.ID, record.Status))
+		return
+	}
+
+	record, _ = s.store.Get(record.ID)
+	writeJSON(w, http.StatusAccepted, record.Summary())
+}
+
+func (s *Server) lookup(w http.ResponseWriter, r *http.Request) (TaskRecord, bool) {
+	id := r.PathValue("id")
+	record, ok := s.store.Get(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("задача %s не найдена", id))
+	}
+	return record, ok
+}
+
+// run выполняет задачу из очереди, если ее не отменили, пока она ждала.
+func (s *Server) run(id string) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+
+	var (
+		record  TaskRecord
+		started = time.Now()
+		skip    bool
+	)
+	s.mu.Lock()
+	err := s.store.Update(id, func(r *TaskRecord) {
+		if r.Status != StatusQueued {
+			skip = true
+			return
+		}
+		r.Status = StatusRunning
+		r.StartedAt = &started
+		record = *r
+	})
+	if err == nil && !skip {
+		s.cancels[id] = cancel
+	}
+	s.mu.Unlock()
+	if err != nil {
+		log.Printf("задача %s: %v", id, err)
+	}
+	if err != nil || skip {
+		return
+	}
+
+	res, err := s.execute(ctx, record)
+
+	s.mu.Lock()
+	delete(s.cancels, id)
+	s.mu.Unlock()
+
+	s.finish(id, res, err)
+	s.notify(id)
+}
+
+func (s *Server) execute(ctx context.Context, record TaskRecord) (agent.TaskResult, error) {
+	incognito, err := s.browser.Incognito()
+	if err != nil {
+		return agent.TaskResult{}, err
+	}
+	defer incognito.Close()
+
+	opts := s.opts
+	opts.RunDir = filepath.Join(s.opts.RunDir, record.ID)
+	aiAgent, err := agent.NewAIAgent(s.provider, incognito, opts)
+	if err != nil {
+		return agent.TaskResult{}, err
+	}
+
+	return aiAgent.ExecuteTaskWithOptions(ctx, record.Task, agent.TaskOptions{
+		SystemPrompt: record.SystemPrompt,
+		Language:     record.Language,
+		Observer:     s.observe(record.ID),
+	})
+}
+
+// observe записывает события задачи в хранилище, ведет по ним журнал шагов
+// и будит подписчиков потока событий.
+func (s *Server) observe(id string) agent.Observer {
+	return agent.ObserverFunc(func(e agent.Event) {
+		if err := s.store.AddEvent(id, e); err != nil {
+			log.Printf("задача %s: %v", id, err)
+		}
+		s.notify(id)
+	})
+}
+
+// watch подписывает на изменения задачи id. Канал получает сигнал после
+// каждого нового события и после завершения задачи.
+func (s *Server) watch(id string) chan struct{} {
+	wake := make(chan struct{}, 1)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.watchers[id] == nil {
+		s.watchers[id] = map[chan struct{}]bool{}
+	}
+	s.watchers[id][wake] = true
+	return wake
+}
+
+func (s *Server) unwatch(id string, wake chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.watchers[id], wake)
+	if len(s.watchers[id]) == 0 {
+		delete(s.watchers, id)
+	}
+}
+
+func (s *Server) notify(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for wake := range s.watchers[id] {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// finish записывает итог задачи: статус определяется по ошибке так же, как
+// в отчетах пакетного режима. Подписчиков будит вызывающий код: finish
+// вызывается и под s.mu.
+func (s *Server) finish(id string, res agent.TaskResult, taskErr error) {
+	finished := time.Now()
+	err := s.store.Update(id, func(r *TaskRecord) {
+		r.Result = res.Result
+		r.Iterations = res.Iterations
+		r.Usage = res.Usage
+		r.CostUSD = res.Cost
+		r.FinishedAt = &finished
+		r.Status = Status(batch.StatusOf(taskErr))
+		if taskErr != nil {
+			r.Error = taskErr.Error()
+		}
+	})
+	if err != nil {
+		log.Printf("задача %s: %v", id, err)
+	}
+}
+
+func newID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// handleEvents отдает события задачи потоком Server-Sent Events: сначала
+// уже произошедшие, затем новые по мере появления. Поток закрывается после
+// завершения задачи. Заголовок Last-Event-ID позволяет продолжить поток
+// после переподключения.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	record, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "потоковая передача не поддерживается")
+		return
+	}
+
+	next := 0
+	if lastID, err := strconv.Atoi(r.Header.Get("Last-Event-ID")); err == nil && lastID > 0 {
+		next = lastID
+	}
+
+	wake := s.watch(record.ID)
+	defer s.unwatch(record.ID, wake)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	keepalive := time.NewTicker(15 * time.Second)
+	defer keepalive.Stop()
+
+	for {
+		record, _ = s.store.Get(record.ID)
+		for ; next < len(record.Events); next++ {
+			event := record.Events[next]
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", next+1, event.Type, event.Data)
+		}
+		flusher.Flush()
+		if record.Status.Finished() {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-wake:
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
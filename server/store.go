@@ -0,0 +1,328 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"ai-browser-agent/agent"
+	"ai-browser-agent/internal/fsutil"
+)
+
+// maxEventLine — предельная длина строки файла событий: события несут
+// полные результаты инструментов.
+const maxEventLine = 16 << 20
+
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSuccess   Status = "success"
+	StatusFailed    Status = "failed"
+	StatusTimeout   Status = "timeout"
+	StatusCancelled Status = "cancelled"
+)
+
+func (s Status) Finished() bool {
+	return s != StatusQueued && s != StatusRunning
+}
+
+// TaskRecord — задача, отправленная через API, вместе с журналом шагов.
+type TaskRecord struct {
+	ID           string        `json:"id"`
+	Task         string        `json:"task"`
+	SystemPrompt string        `json:"system_prompt,omitempty"`
+	Language     string        `json:"language,omitempty"`
+	Status       Status        `json:"status"`
+	Result       string        `json:"result,omitempty"`
+	Error        string        `json:"error,omitempty"`
+	Iterations   int           `json:"iterations"`
+	Usage        agent.Usage   `json:"usage"`
+	CostUSD      float64       `json:"cost_usd"`
+	CreatedAt    time.Time     `json:"created_at"`
+	StartedAt    *time.Time    `json:"started_at,omitempty"`
+	FinishedAt   *time.Time    `json:"finished_at,omitempty"`
+	Steps        []Step        `json:"steps,omitempty"`
+	Events       []EventRecord `json:"events,omitempty"`
+
+	// pendingText — текст ответа модели, который попадет в шаг вместе с
+	// первым из запрошенных ею вызовов.
+	pendingText string
+}
+
+// Summary возвращает задачу без журнала шагов и событий.
+func (r TaskRecord) Summary() TaskRecord {
+	r.Steps = nil
+	r.Events = nil
+	return r
+}
+
+// Step — запись журнала шагов: вызов инструмента с результатом или
+// текстовый ответ модели без вызовов.
+type Step struct {
+	Iteration int       `json:"iteration"`
+	Time      time.Time `json:"time"`
+	// Text — текст, который модель написала вместе с вызовами или вместо них.
+	Text      string `json:"text,omitempty"`
+	Tool      string `json:"tool,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+	Result    string `json:"result,omitempty"`
+	IsError   bool   `json:"is_error,omitempty"`
+}
+
+// EventRecord — событие агента в том виде, в каком оно отдается в потоке.
+type EventRecord struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// addEvent добавляет событие в журнал задачи и дополняет журнал шагов.
+// event — то же событие в виде значения агента.
+func (r *TaskRecord) addEvent(record EventRecord, event agent.Event) {
+	r.Events = append(r.Events, record)
+
+	switch e := event.(type) {
+	case agent.ModelResponded:
+		if len(e.ToolCalls) == 0 {
+			r.Steps = append(r.Steps, Step{Iteration: e.Iteration, Time: e.Time, Text: e.Text})
+		} else {
+			r.pendingText = e.Text
+		}
+	case agent.ToolFinished:
+		r.Steps = append(r.Steps, Step{
+			Iteration: e.Iteration,
+			Time:      e.Time,
+			Text:      r.pendingText,
+			Tool:      e.Call.Name,
+			Arguments: e.Call.Arguments,
+			Result:    e.Result,
+			IsError:   e.IsError,
+		})
+		r.pendingText = ""
+	}
+}
+
+// decodeEvent восстанавливает из записи журнала события, по которым
+// строятся шаги; для остальных возвращает nil.
+func decodeEvent(record EventRecord) (agent.Event, error) {
+	switch record.Type {
+	case agent.ModelResponded{}.EventName():
+		var e agent.ModelResponded
+		err := json.Unmarshal(record.Data, &e)
+		return e, err
+	case agent.ToolFinished{}.EventName():
+		var e agent.ToolFinished
+		err := json.Unmarshal(record.Data, &e)
+		return e, err
+	}
+	return nil, nil
+}
+
+// Store хранит задачи в памяти. Если задан каталог, каждая задача также
+// сохраняется в JSON файл и загружается при следующем запуске сервера.
+// События задачи дописываются в отдельный файл JSON Lines, а файл задачи
+// перезаписывается только при изменении ее состояния, поэтому запись не
+// растет с длиной журнала.
+type Store struct {
+	mu    sync.Mutex
+	dir   string
+	tasks map[string]*TaskRecord
+}
+
+// NewStore создает хранилище. Пустой dir — хранить задачи только в памяти.
+// Задачи, которые не успели завершиться до остановки сервера, при загрузке
+// помечаются как неудачные.
+func NewStore(dir string) (*Store, error) {
+	s := &Store{dir: dir, tasks: map[string]*TaskRecord{}}
+	if dir == "" {
+		return s, nil
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("не удалось создать каталог хранилища %s: %w", dir, err)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось прочитать задачу %s: %w", path, err)
+		}
+		var record TaskRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return nil, fmt.Errorf("не удалось разобрать задачу %s: %w", path, err)
+		}
+		if err := s.loadEvents(&record); err != nil {
+			return nil, err
+		}
+		if !record.Status.Finished() {
+			record.Status = StatusFailed
+			record.Error = "сервер был остановлен до завершения задачи"
+			if err := s.saveLocked(&record); err != nil {
+				return nil, err
+			}
+		}
+		s.tasks[record.ID] = &record
+	}
+	return s, nil
+}
+
+func (s *Store) Add(record *TaskRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tasks[record.ID] = record
+	return s.saveLocked(record)
+}
+
+// Get возвращает копию задачи.
+func (s *Store) Get(id string) (TaskRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.tasks[id]
+	if !ok {
+		return TaskRecord{}, false
+	}
+	return copyRecord(record), true
+}
+
+// List возвращает копии всех задач, новые первыми, без журналов шагов и
+// событий.
+func (s *Store) List() []TaskRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]TaskRecord, 0, len(s.tasks))
+	for _, record := range s.tasks {
+		result = append(result, record.Summary())
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	return result
+}
+
+// AddEvent добавляет событие агента в журнал задачи и дописывает его в
+// файл событий.
+func (s *Store) AddEvent(id string, event agent.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("не удалось сериализовать событие %s: %w", event.EventName(), err)
+	}
+	record := EventRecord{Type: event.EventName(), Data: data}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.tasks[id]
+	if !ok {
+		return fmt.Errorf("задача %s не найдена", id)
+	}
+	task.addEvent(record, event)
+	return s.appendEventLocked(id, record)
+}
+
+// Update изменяет задачу под блокировкой хранилища и сохраняет ее.
+func (s *Store) Update(id string, update func(record *TaskRecord)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.tasks[id]
+	if !ok {
+		return fmt.Errorf("задача %s не найдена", id)
+	}
+	update(record)
+	return s.saveLocked(record)
+}
+
+// saveLocked сохраняет задачу без журналов: они восстанавливаются из файла
+// событий.
+func (s *Store) saveLocked(record *TaskRecord) error {
+	if s.dir == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(record.Summary(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := fsutil.WriteFile(filepath.Join(s.dir, record.ID+".json"), data, 0o644); err != nil {
+		return fmt.Errorf("не удалось сохранить задачу %s: %w", record.ID, err)
+	}
+	return nil
+}
+
+func (s *Store) eventsPath(id string) string {
+	return filepath.Join(s.dir, id+".events.jsonl")
+}
+
+func (s *Store) appendEventLocked(id string, record EventRecord) error {
+	if s.dir == "" {
+		return nil
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(s.eventsPath(id), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить событие задачи %s: %w", id, err)
+	}
+	_, err = file.Write(append(line, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить событие задачи %s: %w", id, err)
+	}
+	return nil
+}
+
+// loadEvents восстанавливает журналы событий и шагов задачи из файла
+// событий. Обрезанная при сбое последняя строка пропускается.
+func (s *Store) loadEvents(record *TaskRecord) error {
+	file, err := os.Open(s.eventsPath(record.ID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать события задачи %s: %w", record.ID, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, maxEventLine)
+	for scanner.Scan() {
+		var event EventRecord
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			break
+		}
+		decoded, err := decodeEvent(event)
+		if err != nil {
+			return fmt.Errorf("не удалось разобрать событие задачи %s: %w", record.ID, err)
+		}
+		record.addEvent(event, decoded)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("не удалось прочитать события задачи %s: %w", record.ID, err)
+	}
+	return nil
+}
+
+func copyRecord(record *TaskRecord) TaskRecord {
+	result := *record
+	result.Steps = append([]Step{}, record.Steps...)
+	result.Events = append([]EventRecord{}, record.Events...)
+	return result
+}
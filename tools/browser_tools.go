@@ -0,0 +1,296 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ai-browser-agent/browser"
+)
+
+func init() {
+	Register(Default, "navigate", "Переходит на указанный URL", navigate)
+	Register(Default, "get_page_content", "Получает содержимое текущей страницы", getPageContent)
+	Register(Default, "get_page_snapshot", "Получает структуру текущей страницы в виде дерева доступности: роли, имена и состояния элементов с номерами ref", getPageSnapshot)
+	Register(Default, "click_element", "Кликает на элемент страницы по CSS селектору или номеру ref", clickElement)
+	Register(Default, "fill_input", "Заполняет поле ввода, заданное CSS селектором или номером ref, текстом", fillInput)
+	Register(Default, "get_elements", "Получает информацию об элементах на странице по селектору", getElements)
+	Register(Default, "list_interactive_elements", "Получает список видимых интерактивных элементов страницы (ссылки, кнопки, поля ввода) с номерами ref и селекторами", listInteractiveElements)
+	Register(Default, "take_screenshot", "Делает скриншот страницы или элемента и сохраняет его в каталог запуска; может приложить изображение к следующему запросу", takeScreenshot)
+	Register(Default, "get_page_info", "Получает информацию о текущей странице", getPageInfo)
+	Register(Default, "wait_for_element", "Ждет появления элемента на странице по CSS селектору или видимости элемента по номеру ref", waitForElement)
+	Register(Default, "list_tabs", "Показывает открытые вкладки браузера и отмечает активную", listTabs)
+	Register(Default, "open_tab", "Открывает новую вкладку, делает ее активной и при необходимости переходит на URL", openTab)
+	Register(Default, "switch_tab", "Делает вкладку активной: все остальные инструменты работают с активной вкладкой", switchTab)
+	Register(Default, "close_tab", "Закрывает вкладку", closeTab)
+	Register(Default, "complete_task", "Завершает задачу", completeTask)
+}
+
+func navigate(ctx context.Context, env *Env, args NavigateArgs) (string, error) {
+	if err := env.Browser.Navigate(ctx, args.URL); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Успешно перешел на страницу: %s", args.URL), nil
+}
+
+func getPageContent(ctx context.Context, env *Env, args NoArgs) (string, error) {
+	html, err := env.Browser.GetPageContent(ctx)
+	if err != nil {
+		return "", err
+	}
+	text, err := env.Browser.GetPageText(ctx)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("HTML (первые %d символов): %s\n\nТекст страницы (первые %d символов): %s",
+		env.Limits.HTMLChars, TruncateString(html, env.Limits.HTMLChars),
+		env.Limits.TextChars, TruncateString(text, env.Limits.TextChars)), nil
+}
+
+func getPageSnapshot(ctx context.Context, env *Env, args NoArgs) (string, error) {
+	return env.Browser.Snapshot(ctx)
+}
+
+func getPageInfo(ctx context.Context, env *Env, args NoArgs) (string, error) {
+	url, err := env.Browser.GetPageURL(ctx)
+	if err != nil {
+		return "", err
+	}
+	title, err := env.Browser.GetPageTitle(ctx)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("URL: %s\nЗаголовок: %s", url, title), nil
+}
+
+func clickElement(ctx context.Context, env *Env, args ClickElementArgs) (string, error) {
+	loc, err := args.Locator()
+	if err != nil {
+		return "", err
+	}
+	resolveSelector(ctx, env, loc)
+	if err := env.Browser.ClickElement(ctx, loc); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Успешно кликнул на элемент: %s", loc) + openedTabsNotice(ctx, env), nil
+}
+
+// resolveSelector запоминает селектор элемента, заданного ref, до
+// действия: после клика страница может измениться и ссылка устареет.
+// Ошибка не мешает действию, оно само сообщит, если элемент не найден.
+func resolveSelector(ctx context.Context, env *Env, loc browser.Locator) {
+	if loc.Ref == 0 {
+		return
+	}
+	if selector, err := env.Browser.ResolveSelector(ctx, loc); err == nil {
+		env.SetSelector(selector)
+	}
+}
+
+func fillInput(ctx context.Context, env *Env, args FillInputArgs) (string, error) {
+	loc, err := args.Locator()
+	if err != nil {
+		return "", err
+	}
+	resolveSelector(ctx, env, loc)
+	if err := env.Browser.FillInput(ctx, loc, args.Text); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Успешно заполнил поле %s текстом: %s", loc, args.Text), nil
+}
+
+func getElements(ctx context.Context, env *Env, args GetElementsArgs) (string, error) {
+	elements, err := env.Browser.GetElements(ctx, args.Selector)
+	if err != nil {
+		return "", err
+	}
+	if len(elements) == 0 {
+		return fmt.Sprintf("Элементы с селектором '%s' не найдены", args.Selector), nil
+	}
+
+	var info strings.Builder
+	info.WriteString(fmt.Sprintf("Найдено элементов: %d\n", len(elements)))
+	for i, elem := range elements {
+		if i >= env.Limits.MatchedElements {
+			info.WriteString(fmt.Sprintf("... и еще %d элементов\n", len(elements)-i))
+			break
+		}
+		elemInfo := fmt.Sprintf("%d. ref=%d, Селектор: %s, Тег: %s, Текст: %s",
+			i+1, elem.Ref, elem.Selector, elem.Tag, TruncateString(elem.Text, 100))
+		if elem.Href != nil {
+			elemInfo += fmt.Sprintf(", Ссылка: %s", *elem.Href)
+		}
+		if elem.ID != nil {
+			elemInfo += fmt.Sprintf(", ID: %s", *elem.ID)
+		}
+		info.WriteString(elemInfo + "\n")
+	}
+	return info.String(), nil
+}
+
+func listInteractiveElements(ctx context.Context, env *Env, args ListInteractiveElementsArgs) (string, error) {
+	elements, err := env.Browser.GetVisibleElements(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var info strings.Builder
+	shown := 0
+	for _, elem := range elements {
+		if args.OnlyInViewport && !elem.InViewport {
+			continue
+		}
+		if shown >= env.Limits.ListedElements {
+			info.WriteString("... список обрезан\n")
+			break
+		}
+		shown++
+
+		elemInfo := fmt.Sprintf("ref=%d <%s> %q, Селектор: %s, Позиция: %.0f,%.0f %.0fx%.0f",
+			elem.Ref, elem.Tag, TruncateString(elem.Text, 80), elem.Selector,
+			elem.Box.X, elem.Box.Y, elem.Box.Width, elem.Box.Height)
+		if elem.Href != nil {
+			elemInfo += fmt.Sprintf(", Ссылка: %s", *elem.Href)
+		}
+		if !elem.InViewport {
+			elemInfo += ", вне экрана"
+		}
+		info.WriteString(elemInfo + "\n")
+	}
+
+	if shown == 0 {
+		return "Интерактивные элементы не найдены", nil
+	}
+	return fmt.Sprintf("Найдено элементов: %d\n%s", shown, info.String()), nil
+}
+
+func takeScreenshot(ctx context.Context, env *Env, args TakeScreenshotArgs) (string, error) {
+	path := filepath.Join(env.RunDir, fmt.Sprintf("screenshot-%s.png", time.Now().Format("150405.000")))
+
+	var (
+		data   []byte
+		legend strings.Builder
+		err    error
+	)
+	if browser.ScreenshotMode(args.Mode) == browser.ScreenshotMarks {
+		var elements []browser.ElementInfo
+		data, elements, err = env.Browser.MarkedScreenshot(ctx, path)
+		if err != nil {
+			return "", err
+		}
+		legend.WriteString("\nОтмеченные элементы:\n")
+		for _, elem := range elements {
+			legend.WriteString(fmt.Sprintf("ref=%d <%s> %q\n", elem.Ref, elem.Tag, TruncateString(elem.Text, 60)))
+		}
+	} else {
+		opts := browser.ScreenshotOptions{
+			Mode: browser.ScreenshotMode(args.Mode),
+			Path: path,
+		}
+		if opts.Mode == browser.ScreenshotElement {
+			loc, err := args.Locator()
+			if err != nil {
+				return "", err
+			}
+			opts.Target = loc
+		}
+
+		data, err = env.Browser.Screenshot(ctx, opts)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	result := fmt.Sprintf("Скриншот сохранен: %s", path)
+	if args.Attach {
+		if !env.Vision {
+			return result + ". Модель не принимает изображения, скриншот не приложен" + legend.String(), nil
+		}
+		env.AttachImage(Image{MIMEType: "image/png", Data: data})
+		result += ". Изображение приложено к следующему сообщению"
+	}
+	return result + legend.String(), nil
+}
+
+func waitForElement(ctx context.Context, env *Env, args WaitForElementArgs) (string, error) {
+	// Нулевой таймаут — ожидание по умолчанию из настроек браузера.
+	timeout := time.Duration(args.Timeout) * time.Second
+	loc, err := args.Locator()
+	if err != nil {
+		return "", err
+	}
+	resolveSelector(ctx, env, loc)
+	if err := env.Browser.WaitForElement(ctx, loc, timeout); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Элемент %s появился", loc), nil
+}
+
+func listTabs(ctx context.Context, env *Env, args NoArgs) (string, error) {
+	tabs, err := env.Browser.ListTabs(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var info strings.Builder
+	info.WriteString(fmt.Sprintf("Открыто вкладок: %d\n", len(tabs)))
+	for _, t := range tabs {
+		info.WriteString(formatTab(t) + "\n")
+	}
+	return info.String(), nil
+}
+
+func openTab(ctx context.Context, env *Env, args OpenTabArgs) (string, error) {
+	id, err := env.Browser.OpenTab(ctx, args.URL)
+	if err != nil {
+		return "", err
+	}
+	if args.URL == "" {
+		return fmt.Sprintf("Открыта пустая вкладка %d, она активна", id), nil
+	}
+	return fmt.Sprintf("Открыта вкладка %d с %s, она активна", id, args.URL), nil
+}
+
+func switchTab(ctx context.Context, env *Env, args TabArgs) (string, error) {
+	if err := env.Browser.SwitchTab(ctx, args.Tab); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Активна вкладка %d", args.Tab), nil
+}
+
+func closeTab(ctx context.Context, env *Env, args TabArgs) (string, error) {
+	if err := env.Browser.CloseTab(ctx, args.Tab); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Вкладка %d закрыта", args.Tab), nil
+}
+
+// openedTabsNotice сообщает модели о вкладках, которые открыла страница,
+// например после клика по ссылке с target=_blank.
+func openedTabsNotice(ctx context.Context, env *Env) string {
+	opened := env.Browser.TakeOpenedTabs(ctx)
+	if len(opened) == 0 {
+		return ""
+	}
+
+	var notice strings.Builder
+	notice.WriteString("\nСтраница открыла новые вкладки:\n")
+	for _, t := range opened {
+		notice.WriteString(formatTab(t) + "\n")
+	}
+	return notice.String()
+}
+
+func formatTab(t browser.TabInfo) string {
+	line := fmt.Sprintf("%d. %s (%s)", t.ID, t.Title, t.URL)
+	if t.Active {
+		line += " — активная"
+	}
+	return line
+}
+
+func completeTask(ctx context.Context, env *Env, args CompleteTaskArgs) (string, error) {
+	return fmt.Sprintf("Задача завершена: %s", args.Result), nil
+}
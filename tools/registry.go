@@ -0,0 +1,230 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"ai-browser-agent/browser"
+)
+
+// Env — окружение, в котором выполняется обработчик инструмента.
+type Env struct {
+	Browser *browser.BrowserManager
+	// RunDir — каталог текущего запуска для скриншотов и других файлов.
+	RunDir string
+	// Vision сообщает, что модель принимает изображения.
+	Vision bool
+	Limits Limits
+
+	images   []Image
+	selector string
+}
+
+// Limits ограничивает объем данных, которые инструменты возвращают модели.
+type Limits struct {
+	// HTMLChars и TextChars — сколько символов HTML и текста страницы
+	// возвращает get_page_content.
+	HTMLChars int
+	TextChars int
+	// MatchedElements — сколько элементов показывает get_elements,
+	// ListedElements — list_interactive_elements.
+	MatchedElements int
+	ListedElements  int
+}
+
+func DefaultLimits() Limits {
+	return Limits{
+		HTMLChars:       5000,
+		TextChars:       3000,
+		MatchedElements: 10,
+		ListedElements:  100,
+	}
+}
+
+type Image struct {
+	MIMEType string
+	Data     []byte
+}
+
+// AttachImage прикладывает изображение к следующему запросу к модели.
+func (e *Env) AttachImage(img Image) {
+	e.images = append(e.images, img)
+}
+
+func (e *Env) Images() []Image {
+	return e.images
+}
+
+// SetSelector запоминает CSS селектор элемента, с которым работал
+// инструмент, когда модель указала элемент номером ref. Селектор
+// возвращается в ToolResult.Selector.
+func (e *Env) SetSelector(selector string) {
+	e.selector = selector
+}
+
+type registeredTool struct {
+	tool Tool
+	call func(ctx context.Context, env *Env, arguments string) (string, error)
+}
+
+// Registry хранит инструменты вместе с их схемами и обработчиками,
+// чтобы описание для модели и исполнение не расходились.
+type Registry struct {
+	tools map[string]registeredTool
+	order []string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{tools: map[string]registeredTool{}}
+}
+
+// Default содержит все браузерные инструменты агента.
+var Default = NewRegistry()
+
+// Register добавляет инструмент в реестр. JSON схема параметров строится
+// по структуре аргументов A: имена берутся из тега json, описания — из
+// тега description, поля без omitempty считаются обязательными.
+func Register[A any](r *Registry, name, description string, handler func(ctx context.Context, env *Env, args A) (string, error)) {
+	if _, exists := r.tools[name]; exists {
+		panic(fmt.Sprintf("инструмент %s уже зарегистрирован", name))
+	}
+
+	var zero A
+	r.tools[name] = registeredTool{
+		tool: Tool{
+			Type: "function",
+			Function: FunctionDefinition{
+				Name:        name,
+				Description: description,
+				Parameters:  schemaFor(reflect.TypeOf(zero)),
+			},
+		},
+		call: func(ctx context.Context, env *Env, arguments string) (string, error) {
+			var args A
+			if strings.TrimSpace(arguments) != "" {
+				if err := ParseArguments(arguments, &args); err != nil {
+					return "", fmt.Errorf("некорректные аргументы: %w", err)
+				}
+			}
+			return handler(ctx, env, args)
+		},
+	}
+	r.order = append(r.order, name)
+}
+
+func (r *Registry) Tools() []Tool {
+	result := make([]Tool, len(r.order))
+	for i, name := range r.order {
+		result[i] = r.tools[name].tool
+	}
+	return result
+}
+
+func (r *Registry) Execute(ctx context.Context, env *Env, toolCallID, name, arguments string) ToolResult {
+	registered, ok := r.tools[name]
+	if !ok {
+		result := NewToolResult(toolCallID, fmt.Sprintf("Неизвестный инструмент: %s", name))
+		result.IsError = true
+		return result
+	}
+
+	content, err := registered.call(ctx, env, arguments)
+	if err != nil {
+		result := NewToolResult(toolCallID, FormatError(err))
+		result.IsError = true
+		return result
+	}
+	result := NewToolResult(toolCallID, content)
+	result.Selector = env.selector
+	return result
+}
+
+func schemaFor(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+
+	if t != nil && t.Kind() == reflect.Struct {
+		collectProperties(t, properties, &required)
+	}
+
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+// collectProperties обходит поля структуры; поля встроенных структур
+// поднимаются на верхний уровень так же, как это делает encoding/json.
+func collectProperties(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+			collectProperties(field.Type, properties, required)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		name, omitempty := jsonFieldName(field)
+		if name == "-" {
+			continue
+		}
+
+		property := typeSchema(field.Type)
+		if desc := field.Tag.Get("description"); desc != "" {
+			property["description"] = desc
+		}
+		properties[name] = property
+
+		if !omitempty {
+			*required = append(*required, name)
+		}
+	}
+}
+
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "" {
+		return field.Name, false
+	}
+
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	if name == "" {
+		name = field.Name
+	}
+
+	omitempty := false
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitempty = true
+		}
+	}
+	return name, omitempty
+}
+
+func typeSchema(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Struct:
+		return schemaFor(t)
+	default:
+		return map[string]interface{}{}
+	}
+}
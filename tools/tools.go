@@ -3,6 +3,8 @@ package tools
 import (
 	"encoding/json"
 	"fmt"
+
+	"ai-browser-agent/browser"
 )
 
 type Tool struct {
@@ -17,142 +19,7 @@ type FunctionDefinition struct {
 }
 
 func GetBrowserTools() []Tool {
-	return []Tool{
-		{
-			Type: "function",
-			Function: FunctionDefinition{
-				Name:        "navigate",
-				Description: "Переходит на указанный URL",
-				Parameters: map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"url": map[string]interface{}{
-							"type":        "string",
-							"description": "URL страницы для перехода",
-						},
-					},
-					"required": []string{"url"},
-				},
-			},
-		},
-		{
-			Type: "function",
-			Function: FunctionDefinition{
-				Name:        "get_page_content",
-				Description: "Получает содержимое текущей страницы",
-				Parameters: map[string]interface{}{
-					"type":       "object",
-					"properties": map[string]interface{}{},
-					"required":   []string{},
-				},
-			},
-		},
-		{
-			Type: "function",
-			Function: FunctionDefinition{
-				Name:        "click_element",
-				Description: "Кликает на элемент страницы по CSS селектору",
-				Parameters: map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"selector": map[string]interface{}{
-							"type":        "string",
-							"description": "CSS селектор элемента",
-						},
-					},
-					"required": []string{"selector"},
-				},
-			},
-		},
-		{
-			Type: "function",
-			Function: FunctionDefinition{
-				Name:        "fill_input",
-				Description: "Заполняет поле ввода текстом",
-				Parameters: map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"selector": map[string]interface{}{
-							"type":        "string",
-							"description": "CSS селектор поля ввода",
-						},
-						"text": map[string]interface{}{
-							"type":        "string",
-							"description": "Текст для ввода",
-						},
-					},
-					"required": []string{"selector", "text"},
-				},
-			},
-		},
-		{
-			Type: "function",
-			Function: FunctionDefinition{
-				Name:        "get_elements",
-				Description: "Получает информацию об элементах на странице по селектору",
-				Parameters: map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"selector": map[string]interface{}{
-							"type":        "string",
-							"description": "CSS селектор для поиска элементов",
-						},
-					},
-					"required": []string{"selector"},
-				},
-			},
-		},
-		{
-			Type: "function",
-			Function: FunctionDefinition{
-				Name:        "get_page_info",
-				Description: "Получает информацию о текущей странице",
-				Parameters: map[string]interface{}{
-					"type":       "object",
-					"properties": map[string]interface{}{},
-					"required":   []string{},
-				},
-			},
-		},
-		{
-			Type: "function",
-			Function: FunctionDefinition{
-				Name:        "wait_for_element",
-				Description: "Ждет появления элемента на странице",
-				Parameters: map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"selector": map[string]interface{}{
-							"type":        "string",
-							"description": "CSS селектор элемента",
-						},
-						"timeout": map[string]interface{}{
-							"type":        "integer",
-							"description": "Время ожидания в секундах",
-						},
-					},
-					"required": []string{"selector"},
-				},
-			},
-		},
-		{
-			Type: "function",
-			Function: FunctionDefinition{
-				Name:        "complete_task",
-				Description: "Завершает задачу",
-				Parameters: map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"result": map[string]interface{}{
-							"type":        "string",
-							"description": "Результат выполнения задачи",
-						},
-					},
-					"required": []string{"result"},
-				},
-			},
-		},
-	}
+	return Default.Tools()
 }
 
 type ToolCall struct {
@@ -174,6 +41,12 @@ type ToolResult struct {
 	ToolCallID string `json:"tool_call_id"`
 	Role       string `json:"role"`
 	Content    string `json:"content"`
+	// IsError означает, что инструмент не выполнился, а Content содержит
+	// текст ошибки для модели.
+	IsError bool `json:"is_error,omitempty"`
+	// Selector — CSS селектор элемента, если инструмент действовал на
+	// элемент по ref.
+	Selector string `json:"selector,omitempty"`
 }
 
 func NewToolResult(toolCallID string, content string) ToolResult {
@@ -188,32 +61,76 @@ func FormatToolsForOpenAI(tools []Tool) []Tool {
 	return tools
 }
 
+type NoArgs struct{}
+
 type NavigateArgs struct {
-	URL string `json:"url"`
+	URL string `json:"url" description:"URL страницы для перехода"`
+}
+
+// ElementTarget — общие аргументы инструментов, действующих на элемент:
+// элемент задается либо CSS селектором, либо номером ref.
+type ElementTarget struct {
+	Selector string `json:"selector,omitempty" description:"CSS селектор элемента"`
+	Ref      int    `json:"ref,omitempty" description:"Номер ref элемента из снимка страницы или списка элементов"`
+}
+
+func (t ElementTarget) Locator() (browser.Locator, error) {
+	if t.Selector == "" && t.Ref == 0 {
+		return browser.Locator{}, fmt.Errorf("нужно указать selector или ref элемента")
+	}
+	if t.Selector != "" && t.Ref != 0 {
+		return browser.Locator{}, fmt.Errorf("укажите только одно из selector или ref")
+	}
+	return browser.Locator{Selector: t.Selector, Ref: t.Ref}, nil
 }
 
 type ClickElementArgs struct {
-	Selector string `json:"selector"`
+	ElementTarget
 }
 
 type FillInputArgs struct {
-	Selector string `json:"selector"`
-	Text     string `json:"text"`
+	ElementTarget
+	Text string `json:"text" description:"Текст для ввода"`
 }
 
 type GetElementsArgs struct {
-	Selector string `json:"selector"`
+	Selector string `json:"selector" description:"CSS селектор для поиска элементов"`
+}
+
+type ListInteractiveElementsArgs struct {
+	OnlyInViewport bool `json:"only_in_viewport,omitempty" description:"Показывать только элементы в видимой области экрана"`
+}
+
+type TakeScreenshotArgs struct {
+	Mode string `json:"mode,omitempty" description:"Режим: viewport (видимая область, по умолчанию), full_page (вся страница), element (один элемент по selector или ref) или marks (видимая область с пронумерованными рамками вокруг элементов, номера совпадают с ref)"`
+	ElementTarget
+	Attach bool `json:"attach,omitempty" description:"Приложить скриншот к следующему запросу, чтобы увидеть страницу"`
+}
+
+type OpenTabArgs struct {
+	URL string `json:"url,omitempty" description:"URL для открытия в новой вкладке"`
+}
+
+type TabArgs struct {
+	Tab int `json:"tab" description:"Номер вкладки из list_tabs"`
 }
 
 type WaitForElementArgs struct {
-	Selector string `json:"selector"`
-	Timeout  int    `json:"timeout,omitempty"`
+	ElementTarget
+	Timeout int `json:"timeout,omitempty" description:"Время ожидания в секундах"`
 }
 
 type CompleteTaskArgs struct {
-	Result string `json:"result"`
+	Result string `json:"result" description:"Результат выполнения задачи"`
 }
 
 func FormatError(err error) string {
 	return fmt.Sprintf("Ошибка: %v", err)
 }
+
+func TruncateString(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	return s[:maxLen] + "..."
+}
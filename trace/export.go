@@ -0,0 +1,195 @@
+package trace
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"ai-browser-agent/browser"
+	"ai-browser-agent/tools"
+)
+
+//go:embed export.go.tmpl
+var exportTemplate string
+
+var exportProgram = template.Must(template.New("export").Funcs(template.FuncMap{
+	"quote":    strconv.Quote,
+	"duration": durationExpr,
+	"comment": func(s string) string {
+		return strings.ReplaceAll(s, "\n", "\n// ")
+	},
+}).Parse(exportTemplate))
+
+// ExportOptions — настройки сценария, который создает Export.
+type ExportOptions struct {
+	// NavigationTimeout и ElementTimeout — ожидание загрузки страницы и
+	// появления элемента в сценарии.
+	NavigationTimeout time.Duration
+	ElementTimeout    time.Duration
+}
+
+type exportData struct {
+	Task              string
+	NavigationTimeout time.Duration
+	ElementTimeout    time.Duration
+	Steps             []exportStep
+	Skipped           []string
+}
+
+// exportStep — шаг сценария: вызов функции-помощника из шаблона с уже
+// закавыченными аргументами.
+type exportStep struct {
+	Name string
+	Call string
+}
+
+// Export превращает вызовы navigate, click_element, fill_input и
+// wait_for_element успешно выполненной задачи в программу на Go, которая
+// повторяет их через rod без агента. После каждого действия сценарий ждет,
+// пока страница успокоится, и проверяет адрес, если действие его изменило.
+// Вызовы, завершившиеся ошибкой, пропускаются; прочие инструменты
+// перечисляются в комментарии программы.
+func Export(t *Trace, opts ExportOptions) ([]byte, error) {
+	if t.Error != "" {
+		return nil, fmt.Errorf("задача завершилась ошибкой, экспортировать можно только успешный запуск: %s", t.Error)
+	}
+
+	data := exportData{
+		Task:              t.Task,
+		NavigationTimeout: opts.NavigationTimeout,
+		ElementTimeout:    opts.ElementTimeout,
+	}
+	if data.NavigationTimeout <= 0 {
+		data.NavigationTimeout = 30 * time.Second
+	}
+	if data.ElementTimeout <= 0 {
+		data.ElementTimeout = 10 * time.Second
+	}
+
+	var url string
+	for i, step := range t.Steps {
+		if step.Type != StepTool || step.Call == nil || step.IsError {
+			continue
+		}
+
+		call, err := exportCall(step, data.ElementTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("шаг %d (%s): %w", i+1, step.Call.Name, err)
+		}
+		if call == nil {
+			if step.Call.Name != "complete_task" {
+				data.Skipped = append(data.Skipped, fmt.Sprintf("шаг %d: %s %s", i+1, step.Call.Name, step.Call.Arguments))
+			}
+			continue
+		}
+		data.Steps = append(data.Steps, *call)
+
+		if step.URL != "" && step.URL != url {
+			url = step.URL
+			data.Steps = append(data.Steps, exportStep{
+				Name: "проверка адреса " + url,
+				Call: fmt.Sprintf("expectURL(page, %s)", strconv.Quote(url)),
+			})
+		}
+	}
+	if len(data.Steps) == 0 {
+		return nil, fmt.Errorf("в трассировке нет действий для экспорта")
+	}
+
+	var buf bytes.Buffer
+	if err := exportProgram.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("не удалось собрать программу: %w", err)
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("не удалось отформатировать программу: %w", err)
+	}
+	return src, nil
+}
+
+// exportCall возвращает шаг сценария для вызова инструмента или nil, если
+// инструмент не переносится в сценарий.
+func exportCall(step Step, elementTimeout time.Duration) (*exportStep, error) {
+	arguments := step.Call.Arguments
+	switch step.Call.Name {
+	case "navigate":
+		var args tools.NavigateArgs
+		if err := tools.ParseArguments(arguments, &args); err != nil {
+			return nil, err
+		}
+		url := browser.NormalizeURL(args.URL)
+		return &exportStep{
+			Name: "переход на " + url,
+			Call: fmt.Sprintf("navigate(page, %s)", strconv.Quote(url)),
+		}, nil
+	case "click_element":
+		var args tools.ClickElementArgs
+		if err := tools.ParseArguments(arguments, &args); err != nil {
+			return nil, err
+		}
+		selector, err := stepSelector(step, args.ElementTarget)
+		if err != nil {
+			return nil, err
+		}
+		return &exportStep{
+			Name: "клик по " + selector,
+			Call: fmt.Sprintf("click(page, %s)", strconv.Quote(selector)),
+		}, nil
+	case "fill_input":
+		var args tools.FillInputArgs
+		if err := tools.ParseArguments(arguments, &args); err != nil {
+			return nil, err
+		}
+		selector, err := stepSelector(step, args.ElementTarget)
+		if err != nil {
+			return nil, err
+		}
+		return &exportStep{
+			Name: "ввод в " + selector,
+			Call: fmt.Sprintf("fill(page, %s, %s)", strconv.Quote(selector), strconv.Quote(args.Text)),
+		}, nil
+	case "wait_for_element":
+		var args tools.WaitForElementArgs
+		if err := tools.ParseArguments(arguments, &args); err != nil {
+			return nil, err
+		}
+		selector, err := stepSelector(step, args.ElementTarget)
+		if err != nil {
+			return nil, err
+		}
+		timeout := elementTimeout
+		if args.Timeout > 0 {
+			timeout = time.Duration(args.Timeout) * time.Second
+		}
+		return &exportStep{
+			Name: "ожидание " + selector,
+			Call: fmt.Sprintf("waitVisible(page, %s, %s)", strconv.Quote(selector), durationExpr(timeout)),
+		}, nil
+	}
+	return nil, nil
+}
+
+// stepSelector возвращает CSS селектор элемента шага. Для элемента,
+// заданного ref, используется селектор, записанный при выполнении.
+func stepSelector(step Step, target tools.ElementTarget) (string, error) {
+	if target.Selector != "" {
+		return target.Selector, nil
+	}
+	if step.Selector != "" {
+		return step.Selector, nil
+	}
+	return "", fmt.Errorf("элемент задан ref=%d, а селектор в трассировке не записан", target.Ref)
+}
+
+// durationExpr записывает d выражением на Go.
+func durationExpr(d time.Duration) string {
+	if d%time.Second == 0 {
+		return fmt.Sprintf("%d * time.Second", d/time.Second)
+	}
+	return fmt.Sprintf("%d * time.Millisecond", d.Milliseconds())
+}
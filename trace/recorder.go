@@ -0,0 +1,176 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"ai-browser-agent/agent"
+	"ai-browser-agent/browser"
+)
+
+// captureTimeout ограничивает снятие адреса и скриншота после действия,
+// чтобы зависшая страница не останавливала задачу.
+const captureTimeout = 10 * time.Second
+
+// Recorder — наблюдатель, который пишет трассировку каждой задачи агента в
+// отдельный подкаталог dir: 001, 002 и так далее. trace.json обновляется
+// после каждого шага, поэтому при аварийном завершении процесса запись
+// сохраняется до последнего действия; index.html создается по завершении
+// задачи.
+type Recorder struct {
+	browser *browser.BrowserManager
+	dir     string
+	count   int
+
+	taskDir string
+	trace   *Trace
+	request string
+	// sent — сообщения последнего записанного запроса.
+	sent []agent.Message
+}
+
+func NewRecorder(bm *browser.BrowserManager, dir string) *Recorder {
+	return &Recorder{browser: bm, dir: dir}
+}
+
+// Factory возвращает agent.ObserverFactory, который пишет трассировки в
+// подкаталог trace каталога запуска агента.
+func Factory() agent.ObserverFactory {
+	return func(bm *browser.BrowserManager, runDir string) agent.Observer {
+		return NewRecorder(bm, filepath.Join(runDir, "trace"))
+	}
+}
+
+// Dir возвращает каталог трассировки последней начатой задачи.
+func (r *Recorder) Dir() string {
+	return r.taskDir
+}
+
+func (r *Recorder) OnEvent(e agent.Event) {
+	if err := r.handle(e); err != nil {
+		log.Printf("трассировка %s: %v", r.taskDir, err)
+	}
+}
+
+func (r *Recorder) handle(e agent.Event) error {
+	if start, ok := e.(agent.TaskStarted); ok {
+		return r.start(start)
+	}
+	if r.trace == nil {
+		return nil
+	}
+
+	switch e := e.(type) {
+	case agent.ModelRequested:
+		if r.trace.SystemPrompt == "" && len(e.Messages) > 0 && e.Messages[0].Role == agent.RoleSystem {
+			r.trace.SystemPrompt = e.Messages[0].Content
+		}
+		file := RequestFile{Messages: e.Messages}
+		if r.request != "" && extends(e.Messages, r.sent) {
+			file.Previous = r.request
+			file.Messages = e.Messages[len(r.sent):]
+		}
+		r.request = filepath.Join("requests", fmt.Sprintf("%03d.json", e.Iteration))
+		r.sent = e.Messages
+		return writeJSON(filepath.Join(r.taskDir, r.request), file)
+	case agent.ModelResponded:
+		usage := e.Usage
+		r.trace.Steps = append(r.trace.Steps, Step{
+			Type:       StepModel,
+			Iteration:  e.Iteration,
+			Time:       e.Time,
+			DurationMS: e.Duration.Milliseconds(),
+			Request:    r.request,
+			Text:       e.Text,
+			ToolCalls:  e.ToolCalls,
+			Usage:      &usage,
+		})
+		return r.trace.Save(r.taskDir)
+	case agent.ToolFinished:
+		call := e.Call
+		step := Step{
+			Type:       StepTool,
+			Iteration:  e.Iteration,
+			Time:       e.Time,
+			DurationMS: e.Duration.Milliseconds(),
+			Call:       &call,
+			Result:     e.Result,
+			IsError:    e.IsError,
+			Selector:   e.Selector,
+		}
+		r.capture(&step, len(r.trace.Steps)+1)
+		r.trace.Steps = append(r.trace.Steps, step)
+		return r.trace.Save(r.taskDir)
+	case agent.TaskFinished:
+		r.trace.FinishedAt = e.Time
+		r.trace.Result = e.Result
+		r.trace.Error = e.Error
+		r.trace.Iterations = e.Iterations
+		r.trace.DurationMS = e.Duration.Milliseconds()
+
+		t := r.trace
+		r.trace = nil
+		if err := t.Save(r.taskDir); err != nil {
+			return err
+		}
+		return WriteReport(r.taskDir, t)
+	}
+	return nil
+}
+
+func (r *Recorder) start(e agent.TaskStarted) error {
+	r.count++
+	r.taskDir = filepath.Join(r.dir, fmt.Sprintf("%03d", r.count))
+	r.request = ""
+	r.sent = nil
+	r.trace = nil
+	if err := os.MkdirAll(filepath.Join(r.taskDir, "requests"), 0o755); err != nil {
+		return fmt.Errorf("не удалось создать каталог трассировки: %w", err)
+	}
+	r.trace = &Trace{Task: e.Task, StartedAt: e.Time, Steps: []Step{}}
+	return r.trace.Save(r.taskDir)
+}
+
+// extends сообщает, что запрос messages продолжает запрос prev: диалог
+// перед запросом только дополнялся, а не сокращался.
+func extends(messages, prev []agent.Message) bool {
+	if len(messages) < len(prev) {
+		return false
+	}
+	for i, m := range prev {
+		cur := messages[i]
+		if cur.Role != m.Role || cur.Content != m.Content || cur.ToolCallID != m.ToolCallID ||
+			len(cur.ToolCalls) != len(m.ToolCalls) || len(cur.Images) != len(m.Images) {
+			return false
+		}
+	}
+	return true
+}
+
+// capture записывает в шаг адрес страницы и скриншот видимой области.
+func (r *Recorder) capture(step *Step, index int) {
+	ctx, cancel := context.WithTimeout(context.Background(), captureTimeout)
+	defer cancel()
+
+	url, err := r.browser.GetPageURL(ctx)
+	if err != nil {
+		step.CaptureError = err.Error()
+		return
+	}
+	step.URL = url
+
+	name := filepath.Join("screenshots", fmt.Sprintf("%03d.png", index))
+	_, err = r.browser.Screenshot(ctx, browser.ScreenshotOptions{
+		Mode: browser.ScreenshotViewport,
+		Path: filepath.Join(r.taskDir, name),
+	})
+	if err != nil {
+		step.CaptureError = err.Error()
+		return
+	}
+	step.Screenshot = name
+}
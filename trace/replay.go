@@ -0,0 +1,109 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"ai-browser-agent/browser"
+	"ai-browser-agent/tools"
+)
+
+// ReplayOptions — настройки воспроизведения трассировки.
+type ReplayOptions struct {
+	// RunDir — каталог для файлов, которые создают инструменты, например
+	// скриншотов.
+	RunDir string
+	Limits tools.Limits
+	// Strict дополнительно сравнивает текст результатов инструментов и
+	// адрес страницы целиком. Без него сравниваются успех вызова и адрес
+	// страницы после него без параметров запроса и фрагмента.
+	Strict bool
+	// OnStep вызывается после каждого воспроизведенного шага.
+	OnStep func(ReplayStep)
+}
+
+// ReplayStep — итог воспроизведения одного вызова инструмента. Diff пуст,
+// если результат совпал с записью.
+type ReplayStep struct {
+	// Index — номер шага в Trace.Steps, начиная с 1.
+	Index    int
+	Recorded Step
+	Result   string
+	IsError  bool
+	URL      string
+	Diff     string
+}
+
+// Replay повторяет вызовы инструментов из t в браузере bm без участия
+// модели и останавливается на первом шаге, результат которого расходится с
+// записью. Возвращает все выполненные шаги; расхождение, если оно есть, —
+// в последнем из них. Ошибка возвращается, только если воспроизведение
+// прервано через ctx.
+func Replay(ctx context.Context, bm *browser.BrowserManager, t *Trace, opts ReplayOptions) ([]ReplayStep, error) {
+	if opts.Limits == (tools.Limits{}) {
+		opts.Limits = tools.DefaultLimits()
+	}
+
+	var replayed []ReplayStep
+	for i, recorded := range t.Steps {
+		if recorded.Type != StepTool || recorded.Call == nil {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return replayed, fmt.Errorf("воспроизведение прервано: %w", err)
+		}
+
+		env := &tools.Env{Browser: bm, RunDir: opts.RunDir, Limits: opts.Limits}
+		result := tools.Default.Execute(ctx, env, recorded.Call.ID, recorded.Call.Name, recorded.Call.Arguments)
+
+		step := ReplayStep{
+			Index:    i + 1,
+			Recorded: recorded,
+			Result:   result.Content,
+			IsError:  result.IsError,
+		}
+		if pageURL, err := bm.GetPageURL(ctx); err == nil {
+			step.URL = pageURL
+		}
+		step.Diff = compare(recorded, step, opts.Strict)
+
+		replayed = append(replayed, step)
+		if opts.OnStep != nil {
+			opts.OnStep(step)
+		}
+		if step.Diff != "" {
+			break
+		}
+	}
+	return replayed, nil
+}
+
+func compare(recorded Step, step ReplayStep, strict bool) string {
+	switch {
+	case recorded.IsError && !step.IsError:
+		return "в записи вызов завершился ошибкой, при воспроизведении — успешно"
+	case !recorded.IsError && step.IsError:
+		return fmt.Sprintf("вызов завершился ошибкой: %s", step.Result)
+	case recorded.URL != "" && !sameURL(recorded.URL, step.URL, strict):
+		return fmt.Sprintf("адрес страницы %s, в записи %s", step.URL, recorded.URL)
+	case strict && recorded.Result != step.Result:
+		return "результат отличается от записи"
+	}
+	return ""
+}
+
+// sameURL сравнивает адреса страниц. Без strict параметры запроса и
+// фрагмент не учитываются: идентификаторы сессий и метки отслеживания
+// меняются от запуска к запуску.
+func sameURL(recorded, replayed string, strict bool) bool {
+	if strict || recorded == replayed {
+		return recorded == replayed
+	}
+	a, errA := url.Parse(recorded)
+	b, errB := url.Parse(replayed)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return a.Scheme == b.Scheme && a.Host == b.Host && a.Path == b.Path
+}
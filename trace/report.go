@@ -0,0 +1,48 @@
+package trace
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+)
+
+//go:embed report.html.tmpl
+var reportTemplate string
+
+var report = template.Must(template.New("report").Parse(reportTemplate))
+
+type reportData struct {
+	Trace *Trace
+	// Screenshots — скриншоты шагов в виде data URL, по индексу шага.
+	// Встраиваются в страницу, чтобы отчет открывался без остальных файлов.
+	Screenshots []string
+}
+
+// WriteReport создает в каталоге трассировки dir отчет index.html, в
+// котором по шагам просматривается запись t.
+func WriteReport(dir string, t *Trace) error {
+	data := reportData{Trace: t, Screenshots: make([]string, len(t.Steps))}
+	for i, step := range t.Steps {
+		if step.Screenshot == "" {
+			continue
+		}
+		png, err := os.ReadFile(filepath.Join(dir, step.Screenshot))
+		if err != nil {
+			continue
+		}
+		data.Screenshots[i] = "data:image/png;base64," + base64.StdEncoding.EncodeToString(png)
+	}
+
+	var buf bytes.Buffer
+	if err := report.Execute(&buf, data); err != nil {
+		return fmt.Errorf("не удалось собрать отчет: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ReportName), buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("не удалось сохранить отчет: %w", err)
+	}
+	return nil
+}
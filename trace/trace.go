@@ -0,0 +1,107 @@
+// Package trace записывает ход выполнения задачи агентом: запросы к модели
+// и ее ответы, вызовы инструментов с результатами, адрес страницы и
+// скриншот после каждого действия. Трассировка сохраняется в каталог с
+// trace.json и самодостаточным HTML отчетом index.html.
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"ai-browser-agent/agent"
+	"ai-browser-agent/internal/fsutil"
+)
+
+// Имена файлов в каталоге трассировки.
+const (
+	FileName   = "trace.json"
+	ReportName = "index.html"
+)
+
+type StepType string
+
+const (
+	StepModel StepType = "model"
+	StepTool  StepType = "tool"
+)
+
+// Trace — запись одной задачи.
+type Trace struct {
+	Task         string    `json:"task"`
+	SystemPrompt string    `json:"system_prompt,omitempty"`
+	StartedAt    time.Time `json:"started_at"`
+	FinishedAt   time.Time `json:"finished_at"`
+	Result       string    `json:"result,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	Iterations   int       `json:"iterations"`
+	DurationMS   int64     `json:"duration_ms"`
+	Steps        []Step    `json:"steps"`
+}
+
+// Step — ответ модели (StepModel) или вызов инструмента (StepTool).
+// Пути Request и Screenshot заданы относительно каталога трассировки.
+type Step struct {
+	Type       StepType  `json:"type"`
+	Iteration  int       `json:"iteration"`
+	Time       time.Time `json:"time"`
+	DurationMS int64     `json:"duration_ms"`
+
+	// Request — файл RequestFile с сообщениями, отправленными модели.
+	Request   string           `json:"request,omitempty"`
+	Text      string           `json:"text,omitempty"`
+	ToolCalls []agent.ToolCall `json:"tool_calls,omitempty"`
+	Usage     *agent.Usage     `json:"usage,omitempty"`
+
+	Call    *agent.ToolCall `json:"call,omitempty"`
+	Result  string          `json:"result,omitempty"`
+	IsError bool            `json:"is_error,omitempty"`
+	// Selector — CSS селектор элемента, заданного в вызове номером ref.
+	Selector string `json:"selector,omitempty"`
+	// URL и Screenshot — состояние страницы после вызова инструмента.
+	URL        string `json:"url,omitempty"`
+	Screenshot string `json:"screenshot,omitempty"`
+	// CaptureError — почему не удалось получить адрес или скриншот.
+	CaptureError string `json:"capture_error,omitempty"`
+}
+
+// RequestFile — запрос к модели в каталоге requests. Если задан Previous,
+// Messages содержит только сообщения, добавленные после запроса из этого
+// файла: так скриншоты в диалоге записываются один раз, а не в каждый
+// следующий запрос. Первый запрос задачи и запрос после сокращения
+// контекста записываются целиком.
+type RequestFile struct {
+	Previous string          `json:"previous,omitempty"`
+	Messages []agent.Message `json:"messages"`
+}
+
+// Load читает trace.json из каталога трассировки dir.
+func Load(dir string) (*Trace, error) {
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать трассировку: %w", err)
+	}
+	var t Trace
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("не удалось разобрать трассировку %s: %w", dir, err)
+	}
+	return &t, nil
+}
+
+// Save записывает trace.json в каталог dir.
+func (t *Trace) Save(dir string) error {
+	return writeJSON(filepath.Join(dir, FileName), t)
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := fsutil.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("не удалось сохранить %s: %w", path, err)
+	}
+	return nil
+}